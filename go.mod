@@ -8,6 +8,14 @@ require (
 	github.com/aws/aws-cdk-go/awscdk/v2 v2.199.0
 	github.com/aws/constructs-go/constructs/v10 v10.4.2
 	github.com/aws/jsii-runtime-go v1.112.0
+	serp/services/shared/saga v0.0.0-00010101000000-000000000000
+)
+
+require serp/services/shared/events v0.0.0-00010101000000-000000000000 // indirect
+
+replace (
+	serp/services/shared/events => ./services/shared/events
+	serp/services/shared/saga => ./services/shared/saga
 )
 
 require (
@@ -0,0 +1,419 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenName
+	tokenPunct
+	tokenString
+	tokenNumber
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+type lexer struct {
+	src  []rune
+	pos  int
+	line int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: []rune(src), line: 1}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+
+	start := l.pos
+	r := l.src[l.pos]
+	switch {
+	case r == '_' || unicode.IsLetter(r):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || unicode.IsLetter(l.src[l.pos]) || unicode.IsDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokenName, value: string(l.src[start:l.pos]), line: l.line}, nil
+	case r == '-' || unicode.IsDigit(r):
+		l.pos++
+		for l.pos < len(l.src) && strings.ContainsRune("0123456789.eE+-", l.src[l.pos]) {
+			l.pos++
+		}
+		return token{kind: tokenNumber, value: string(l.src[start:l.pos]), line: l.line}, nil
+	case r == '"':
+		return l.readString()
+	case r == '.':
+		if l.pos+2 < len(l.src) && l.src[l.pos+1] == '.' && l.src[l.pos+2] == '.' {
+			l.pos += 3
+			return token{kind: tokenPunct, value: "...", line: l.line}, nil
+		}
+	case strings.ContainsRune("{}()[]:!=@|&$", r):
+		l.pos++
+		return token{kind: tokenPunct, value: string(r), line: l.line}, nil
+	}
+	return token{}, fmt.Errorf("line %d: unexpected character %q", l.line, r)
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch r := l.src[l.pos]; {
+		case r == '\n':
+			l.line++
+			l.pos++
+		case r == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case r == ',' || r == '\uFEFF' || unicode.IsSpace(r):
+			l.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) readString() (token, error) {
+	line := l.line
+	if strings.HasPrefix(string(l.src[l.pos:min(l.pos+3, len(l.src))]), `"""`) {
+		l.pos += 3
+		start := l.pos
+		for l.pos < len(l.src) {
+			if strings.HasPrefix(string(l.src[l.pos:min(l.pos+3, len(l.src))]), `"""`) {
+				value := string(l.src[start:l.pos])
+				l.pos += 3
+				return token{kind: tokenString, value: value, line: line}, nil
+			}
+			if l.src[l.pos] == '\n' {
+				l.line++
+			}
+			l.pos++
+		}
+		return token{}, fmt.Errorf("line %d: unterminated block string", line)
+	}
+
+	l.pos++
+	start := l.pos
+	for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+		switch l.src[l.pos] {
+		case '\\':
+			l.pos += 2
+			continue
+		case '"':
+			value := string(l.src[start:l.pos])
+			l.pos++
+			return token{kind: tokenString, value: value, line: line}, nil
+		}
+		l.pos++
+	}
+	return token{}, fmt.Errorf("line %d: unterminated string", line)
+}
+
+type parser struct {
+	lex  *lexer
+	tok  token
+	peek *token
+}
+
+func (p *parser) advance() error {
+	if p.peek != nil {
+		p.tok = *p.peek
+		p.peek = nil
+		return nil
+	}
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) lookahead() (token, error) {
+	if p.peek == nil {
+		tok, err := p.lex.next()
+		if err != nil {
+			return token{}, err
+		}
+		p.peek = &tok
+	}
+	return *p.peek, nil
+}
+
+func (p *parser) is(value string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == value
+}
+
+func (p *parser) expect(value string) error {
+	if !p.is(value) {
+		return p.errorf("expected %q, found %q", value, p.tok.value)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectName() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected name, found %q", p.tok.value)
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("graphql: line %d: %s", p.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) parseDocument(schema *Schema) error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	for p.tok.kind != tokenEOF {
+		if p.tok.kind == tokenString {
+			if err := p.advance(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		keyword, err := p.expectName()
+		if err != nil {
+			return err
+		}
+		if keyword == "extend" {
+			if keyword, err = p.expectName(); err != nil {
+				return err
+			}
+		}
+
+		switch keyword {
+		case "type":
+			if err := p.parseObjectType(schema); err != nil {
+				return err
+			}
+		case "schema":
+			if err := p.parseSchemaDefinition(schema); err != nil {
+				return err
+			}
+		case "input", "enum", "interface", "union", "scalar", "directive":
+			if err := p.skipDefinition(); err != nil {
+				return err
+			}
+		default:
+			return p.errorf("unexpected definition %q", keyword)
+		}
+	}
+	return nil
+}
+
+func (p *parser) parseSchemaDefinition(schema *Schema) error {
+	if err := p.skipDirectives(); err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for !p.is("}") {
+		operation, err := p.expectName()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		typeName, err := p.expectName()
+		if err != nil {
+			return err
+		}
+		switch operation {
+		case "query":
+			schema.Query = typeName
+		case "mutation":
+			schema.Mutation = typeName
+		}
+	}
+	return p.advance()
+}
+
+func (p *parser) parseObjectType(schema *Schema) error {
+	name, err := p.expectName()
+	if err != nil {
+		return err
+	}
+	if p.tok.kind == tokenName && p.tok.value == "implements" {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		for p.tok.kind == tokenName || p.is("&") {
+			if err := p.advance(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := p.skipDirectives(); err != nil {
+		return err
+	}
+
+	objectType, ok := schema.Types[name]
+	if !ok {
+		objectType = &ObjectType{Name: name}
+		schema.Types[name] = objectType
+		schema.order = append(schema.order, name)
+	}
+	if !p.is("{") {
+		return nil
+	}
+	if err := p.advance(); err != nil {
+		return err
+	}
+
+	for !p.is("}") {
+		field, err := p.parseField(name)
+		if err != nil {
+			return err
+		}
+		objectType.Fields = append(objectType.Fields, field)
+	}
+	return p.advance()
+}
+
+func (p *parser) parseField(typeName string) (Field, error) {
+	if p.tok.kind == tokenString {
+		if err := p.advance(); err != nil {
+			return Field{}, err
+		}
+	}
+	name, err := p.expectName()
+	if err != nil {
+		return Field{}, err
+	}
+	if p.is("(") {
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return Field{}, err
+		}
+	}
+	if err := p.expect(":"); err != nil {
+		return Field{}, err
+	}
+	returnType, err := p.parseTypeReference()
+	if err != nil {
+		return Field{}, err
+	}
+	if err := p.skipDirectives(); err != nil {
+		return Field{}, err
+	}
+	return Field{TypeName: typeName, Name: name, ReturnType: returnType}, nil
+}
+
+// parseTypeReference returns the named type of a reference such as
+// [Item!]!, dropping list and non-null wrappers.
+func (p *parser) parseTypeReference() (string, error) {
+	if p.is("[") {
+		if err := p.advance(); err != nil {
+			return "", err
+		}
+		name, err := p.parseTypeReference()
+		if err != nil {
+			return "", err
+		}
+		if err := p.expect("]"); err != nil {
+			return "", err
+		}
+		if p.is("!") {
+			return name, p.advance()
+		}
+		return name, nil
+	}
+
+	name, err := p.expectName()
+	if err != nil {
+		return "", err
+	}
+	if p.is("!") {
+		return name, p.advance()
+	}
+	return name, nil
+}
+
+func (p *parser) skipDirectives() error {
+	for p.is("@") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if _, err := p.expectName(); err != nil {
+			return err
+		}
+		if p.is("(") {
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *parser) skipBalanced(open, close string) error {
+	depth := 0
+	for {
+		switch {
+		case p.tok.kind == tokenEOF:
+			return p.errorf("unexpected end of schema, missing %q", close)
+		case p.is(open):
+			depth++
+		case p.is(close):
+			depth--
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// skipDefinition consumes a definition the resolver wiring does not need.
+// Definitions without a body end where the next top-level keyword begins.
+func (p *parser) skipDefinition() error {
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.is("{"):
+			return p.skipBalanced("{", "}")
+		case p.is("("):
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return err
+			}
+			continue
+		case p.tok.kind == tokenName && isDefinitionKeyword(p.tok.value):
+			next, err := p.lookahead()
+			if err != nil {
+				return err
+			}
+			if next.kind == tokenName || (p.tok.value == "schema" && next.kind == tokenPunct && next.value == "{") {
+				return nil
+			}
+		}
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDefinitionKeyword(value string) bool {
+	switch value {
+	case "type", "input", "enum", "interface", "union", "scalar", "directive", "schema", "extend":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func fieldNames(fields []Field) []string {
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.String()
+	}
+	return names
+}
+
+func TestParseSchemaFile(t *testing.T) {
+	schema, err := ParseFile("../../schema.graphql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Query != "Query" || schema.Mutation != "Mutation" {
+		t.Errorf("root types %s and %s, want Query and Mutation", schema.Query, schema.Mutation)
+	}
+	for _, name := range []string{"Item", "Order", "OrderItem", "Query", "Mutation", "InventoryQueries", "InventoryMutations", "OrderQueries", "OrderMutations", "ItemConnection", "OrderConnection"} {
+		if _, ok := schema.Types[name]; !ok {
+			t.Errorf("type %s was not parsed", name)
+		}
+	}
+	for _, name := range []string{"OrderStatus", "CreateItemInput", "OrderFilterInput"} {
+		if _, ok := schema.Types[name]; ok {
+			t.Errorf("non-object type %s was parsed as an object type", name)
+		}
+	}
+
+	want := map[string]string{
+		"Query.inventory":     "InventoryQueries",
+		"Query.listItems":     "ItemConnection",
+		"Mutation.deleteItem": "Boolean",
+		"Order.items":         "OrderItem",
+	}
+	for _, objectType := range schema.Types {
+		for _, field := range objectType.Fields {
+			if returnType, ok := want[field.String()]; ok {
+				if field.ReturnType != returnType {
+					t.Errorf("%s returns %s, want %s", field, field.ReturnType, returnType)
+				}
+				delete(want, field.String())
+			}
+		}
+	}
+	for field := range want {
+		t.Errorf("field %s was not parsed", field)
+	}
+
+	inventory, err := schema.Claim("inventory", []string{"InventoryQueries", "InventoryMutations"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInventory := []string{
+		"Query.inventory", "Query.getItem", "Query.listItems",
+		"Mutation.inventory", "Mutation.createItem", "Mutation.updateItem", "Mutation.deleteItem",
+		"InventoryQueries.getItem", "InventoryQueries.listItems",
+		"InventoryMutations.createItem", "InventoryMutations.updateItem", "InventoryMutations.deleteItem",
+	}
+	if got := fieldNames(inventory); fmt.Sprint(got) != fmt.Sprint(wantInventory) {
+		t.Errorf("inventory claimed %v, want %v", got, wantInventory)
+	}
+
+	if err := schema.Validate(); err == nil || !strings.Contains(err.Error(), "Query.orders") {
+		t.Errorf("validating with the orders fields unclaimed returned %v", err)
+	}
+	if _, err := schema.Claim("orders", []string{"OrderQueries", "OrderMutations"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Validate(); err != nil {
+		t.Errorf("every root field is claimed, but validating returned %v", err)
+	}
+	if owner, _ := schema.Owner(Field{TypeName: "Mutation", Name: "orders", ReturnType: "OrderMutations"}); owner != "orders" {
+		t.Errorf("Mutation.orders is owned by %q, want orders", owner)
+	}
+}
+
+func TestParse(t *testing.T) {
+	schema, err := Parse(`
+"""
+The schema, with renamed roots.
+"""
+schema @aws_iam { query: RootQuery, mutation: RootMutation }
+
+scalar AWSDateTime
+directive @aws_iam on OBJECT | FIELD_DEFINITION
+enum Color { RED GREEN }
+union Result = Widget | Gadget
+input WidgetInput { name: String! = "widget", tags: [String!] }
+
+interface Named { name: String! }
+
+"A widget"
+type Widget implements Named & Node @aws_iam {
+  "Its name"
+  name: String!
+  parts(first: Int = 10, filter: WidgetInput): [Part!]! @deprecated(reason: "use components")
+  sizes: [[Int]]
+}
+
+type RootQuery {
+  widget(id: ID!): Widget
+}
+
+extend type RootQuery {
+  widgets: [Widget]
+}
+
+type RootMutation
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Query != "RootQuery" || schema.Mutation != "RootMutation" {
+		t.Errorf("root types %s and %s, want RootQuery and RootMutation", schema.Query, schema.Mutation)
+	}
+
+	want := map[string][]Field{
+		"Widget": {
+			{TypeName: "Widget", Name: "name", ReturnType: "String"},
+			{TypeName: "Widget", Name: "parts", ReturnType: "Part"},
+			{TypeName: "Widget", Name: "sizes", ReturnType: "Int"},
+		},
+		"RootQuery": {
+			{TypeName: "RootQuery", Name: "widget", ReturnType: "Widget"},
+			{TypeName: "RootQuery", Name: "widgets", ReturnType: "Widget"},
+		},
+		"RootMutation": nil,
+	}
+	if len(schema.Types) != len(want) {
+		t.Errorf("parsed types %v, want %d", schema.order, len(want))
+	}
+	for name, fields := range want {
+		objectType, ok := schema.Types[name]
+		if !ok {
+			t.Errorf("type %s was not parsed", name)
+			continue
+		}
+		if fmt.Sprint(objectType.Fields) != fmt.Sprint(fields) {
+			t.Errorf("type %s has fields %v, want %v", name, objectType.Fields, fields)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"unknown definition", "typo Item { id: ID }", `unexpected definition "typo"`},
+		{"field without type", "type Item {\n  id\n}", `line 3: expected ":"`},
+		{"missing return type", "type Item { id: }", "expected name"},
+		{"unclosed type", "type Item {\n  id: ID", "expected name"},
+		{"unclosed arguments", "type Query { item(id: ID: Item }", `missing ")"`},
+		{"unclosed list", "type Item { tags: [String }", `expected "]"`},
+		{"unclosed input", "input ItemInput { name: String", `missing "}"`},
+		{"unterminated string", "\"An item\ntype Item { id: ID }", "line 1: unterminated string"},
+		{"unterminated block string", "\"\"\"An item\n\ntype Item { id: ID }", "line 1: unterminated block string"},
+		{"unexpected character", "type Item { id: ID% }", `unexpected character '%'`},
+		{"schema without root type", "schema { query: }", "expected name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.src)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse returned %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestClaim(t *testing.T) {
+	schema, err := Parse(`
+type Query {
+  inventory: InventoryQueries
+  getItem(id: ID!): Item
+  health: String
+}
+type InventoryQueries { getItem(id: ID!): Item }
+type Item { id: ID! }
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := schema.Claim("inventory", []string{"Query"}); err == nil {
+		t.Error("claiming a root type succeeded")
+	}
+	if _, err := schema.Claim("inventory", []string{"Missing"}); err == nil {
+		t.Error("claiming an undefined type succeeded")
+	}
+	if _, err := schema.Claim("inventory", []string{"InventoryQueries"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := schema.Claim("inventory", []string{"InventoryQueries"}); err != nil {
+		t.Errorf("claiming again for the same owner returned %v", err)
+	}
+	if _, err := schema.Claim("orders", []string{"InventoryQueries"}); err == nil || !strings.Contains(err.Error(), "claimed by both") {
+		t.Errorf("claiming for another owner returned %v", err)
+	}
+	if err := schema.Validate(); err == nil || !strings.Contains(err.Error(), "Query.health") {
+		t.Errorf("validating with Query.health unclaimed returned %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package graphql
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Field is a field declared on a GraphQL object type.
+type Field struct {
+	TypeName   string
+	Name       string
+	ReturnType string
+}
+
+func (f Field) String() string {
+	return f.TypeName + "." + f.Name
+}
+
+type ObjectType struct {
+	Name   string
+	Fields []Field
+}
+
+// Schema is the subset of a GraphQL SDL document needed to wire resolvers:
+// object types, their fields and the root operation types. It also tracks
+// which service owns each resolvable field.
+type Schema struct {
+	Query    string
+	Mutation string
+	Types    map[string]*ObjectType
+
+	order  []string
+	owners map[Field]string
+}
+
+func ParseFile(path string) (*Schema, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %v", err)
+	}
+	return Parse(string(src))
+}
+
+func Parse(src string) (*Schema, error) {
+	p := &parser{lex: newLexer(src)}
+	schema := &Schema{
+		Query:    "Query",
+		Mutation: "Mutation",
+		Types:    make(map[string]*ObjectType),
+		owners:   make(map[Field]string),
+	}
+	if err := p.parseDocument(schema); err != nil {
+		return nil, err
+	}
+	return schema, nil
+}
+
+// Claim records owner as the service resolving every field of the given
+// namespace types, together with the root fields that expose them: root
+// fields returning one of the types (e.g. Query.inventory) and root fields
+// mirroring a field of a namespace type (e.g. Query.getItem). The claimed
+// fields are returned in schema order.
+func (s *Schema) Claim(owner string, typeNames []string) ([]Field, error) {
+	owned := make(map[string]bool, len(typeNames))
+	for _, name := range typeNames {
+		if name == s.Query || name == s.Mutation {
+			return nil, fmt.Errorf("root type %s cannot be owned by %s, claim its namespace types instead", name, owner)
+		}
+		if _, ok := s.Types[name]; !ok {
+			return nil, fmt.Errorf("type %s claimed by %s is not defined in the schema", name, owner)
+		}
+		owned[name] = true
+	}
+
+	var fields []Field
+	for _, root := range []string{s.Query, s.Mutation} {
+		fields = append(fields, s.ownedRootFields(root, owned)...)
+	}
+	for _, name := range s.order {
+		if owned[name] {
+			fields = append(fields, s.Types[name].Fields...)
+		}
+	}
+
+	for _, field := range fields {
+		if current, ok := s.owners[field]; ok && current != owner {
+			return nil, fmt.Errorf("field %s is claimed by both %s and %s", field, current, owner)
+		}
+	}
+	for _, field := range fields {
+		s.owners[field] = owner
+	}
+
+	return fields, nil
+}
+
+func (s *Schema) ownedRootFields(root string, owned map[string]bool) []Field {
+	rootType, ok := s.Types[root]
+	if !ok {
+		return nil
+	}
+
+	mirrored := make(map[string]bool)
+	for _, field := range rootType.Fields {
+		if !owned[field.ReturnType] {
+			continue
+		}
+		for _, nested := range s.Types[field.ReturnType].Fields {
+			mirrored[nested.Name] = true
+		}
+	}
+
+	var fields []Field
+	for _, field := range rootType.Fields {
+		if owned[field.ReturnType] || mirrored[field.Name] {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// Owner returns the service that claimed field, if any.
+func (s *Schema) Owner(field Field) (string, bool) {
+	owner, ok := s.owners[field]
+	return owner, ok
+}
+
+// Validate fails if any root field has no owning service, since AppSync
+// would otherwise serve it without a resolver.
+func (s *Schema) Validate() error {
+	var unowned []string
+	for _, root := range []string{s.Query, s.Mutation} {
+		rootType, ok := s.Types[root]
+		if !ok {
+			continue
+		}
+		for _, field := range rootType.Fields {
+			if _, ok := s.owners[field]; !ok {
+				unowned = append(unowned, field.String())
+			}
+		}
+	}
+	if len(unowned) > 0 {
+		sort.Strings(unowned)
+		return fmt.Errorf("no service owns schema fields: %s", strings.Join(unowned, ", "))
+	}
+	return nil
+}
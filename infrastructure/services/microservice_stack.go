@@ -1,11 +1,21 @@
 package services
 
 import (
+	"strings"
+
+	"serp/infrastructure/golambda"
+	"serp/infrastructure/graphql"
+
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsappsync"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsdynamodb"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambdadestinations"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslambdaeventsources"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssqs"
 	"github.com/aws/constructs-go/constructs/v10"
 	"github.com/aws/jsii-runtime-go"
 )
@@ -17,6 +27,38 @@ type MicroserviceStackProps struct {
 	SecurityGroupId *string
 	LambdaRoleArn   *string
 	GraphqlApiId    *string
+	// Schema is the parsed unified schema the service's resolvers are
+	// registered against.
+	Schema *graphql.Schema
+	// GraphqlTypes lists the namespace types the service resolves, e.g.
+	// InventoryQueries. Every field on them, and the root fields exposing
+	// them, gets a resolver on the service's Lambda data source.
+	GraphqlTypes []string
+	// Subscriptions selects the events on erp-event-bus delivered to the
+	// service's eventbridge handler.
+	Subscriptions []EventSubscription
+	// Indexes are global secondary indexes added to the service table.
+	Indexes []TableIndex
+	// SagaTasks deploys the service's saga function, which runs its tasks
+	// in sagas, and exports its ARN for SagaFunctionArnExport.
+	SagaTasks bool
+	// ReadsTablesOf lists the services whose tables the functions may
+	// read, such as orders pricing its lines from inventory. Each table's
+	// name is set as <SERVICE>_TABLE_NAME.
+	ReadsTablesOf []string
+}
+
+// TableIndex is a global secondary index keyed on string attributes.
+type TableIndex struct {
+	Name         string
+	PartitionKey string
+	SortKey      string
+}
+
+// EventSubscription matches events from Source with any of DetailTypes.
+type EventSubscription struct {
+	Source      string
+	DetailTypes []string
 }
 
 func NewMicroserviceStack(scope constructs.Construct, id string, props *MicroserviceStackProps) awscdk.Stack {
@@ -37,6 +79,33 @@ func NewMicroserviceStack(scope constructs.Construct, id string, props *Microser
 		},
 		BillingMode: awsdynamodb.BillingMode_PAY_PER_REQUEST,
 		Stream:      awsdynamodb.StreamViewType_NEW_AND_OLD_IMAGES,
+		// Idempotency records expire through the table's TTL.
+		TimeToLiveAttribute: jsii.String("expires_at"),
+	})
+
+	for _, index := range props.Indexes {
+		indexProps := &awsdynamodb.GlobalSecondaryIndexProps{
+			IndexName: jsii.String(index.Name),
+			PartitionKey: &awsdynamodb.Attribute{
+				Name: jsii.String(index.PartitionKey),
+				Type: awsdynamodb.AttributeType_STRING,
+			},
+		}
+		if index.SortKey != "" {
+			indexProps.SortKey = &awsdynamodb.Attribute{
+				Name: jsii.String(index.SortKey),
+				Type: awsdynamodb.AttributeType_STRING,
+			}
+		}
+		table.AddGlobalSecondaryIndex(indexProps)
+	}
+
+	cursorSecret := awssecretsmanager.NewSecret(stack, jsii.String(props.ServiceName+"CursorSecret"), &awssecretsmanager.SecretProps{
+		Description: jsii.String("Key encrypting " + props.ServiceName + " pagination cursors"),
+		GenerateSecretString: &awssecretsmanager.SecretStringGenerator{
+			PasswordLength:     jsii.Number(64),
+			ExcludePunctuation: jsii.Bool(true),
+		},
 	})
 
 	api := awsappsync.GraphqlApi_FromGraphqlApiAttributes(stack, jsii.String(props.ServiceName+"Api"), &awsappsync.GraphqlApiAttributes{
@@ -44,138 +113,207 @@ func NewMicroserviceStack(scope constructs.Construct, id string, props *Microser
 		GraphqlApiArn: awscdk.Fn_ImportValue(jsii.String("ErpGraphqlApiUrl")),
 	})
 
+	eventBus := awsevents.EventBus_FromEventBusName(stack, jsii.String(props.ServiceName+"EventBus"), awscdk.Fn_ImportValue(jsii.String("ErpEventBusName")))
+
 	environment := &map[string]*string{
-		"TABLE_NAME":   table.TableName(),
-		"API_URL":      awscdk.Fn_ImportValue(jsii.String("ErpGraphqlApiUrl")),
-		"SERVICE_NAME": jsii.String(props.ServiceName),
-		"EVENT_BUS":    awscdk.Fn_ImportValue(jsii.String("ErpEventBusName")),
-		"LOG_LEVEL":    jsii.String("INFO"),
+		"TABLE_NAME":        table.TableName(),
+		"API_URL":           awscdk.Fn_ImportValue(jsii.String("ErpGraphqlApiUrl")),
+		"SERVICE_NAME":      jsii.String(props.ServiceName),
+		"EVENT_BUS_NAME":    eventBus.EventBusName(),
+		"CURSOR_SECRET_ARN": cursorSecret.SecretArn(),
+		"LOG_LEVEL":         jsii.String("INFO"),
+	}
+
+	var readTables []awsdynamodb.ITable
+	for _, service := range props.ReadsTablesOf {
+		readTable := awsdynamodb.Table_FromTableName(stack, jsii.String(pascalCase(service)+"Table"),
+			awscdk.Fn_ImportValue(jsii.String(TableNameExport(service))))
+		(*environment)[strings.ToUpper(service)+"_TABLE_NAME"] = readTable.TableName()
+		readTables = append(readTables, readTable)
+	}
+
+	newFunction := func(entrypoint string) awslambda.Function {
+		function := golambda.NewGoFunction(stack, props.ServiceName+pascalCase(entrypoint)+"Function", &golambda.GoFunctionProps{
+			Root:        "services",
+			Module:      props.ServiceName + "/lambda",
+			Package:     "cmd/" + props.ServiceName + "-" + entrypoint,
+			Environment: environment,
+			Timeout:     awscdk.Duration_Seconds(jsii.Number(30)),
+			MemorySize:  jsii.Number(256),
+		})
+		table.GrantReadWriteData(function)
+		for _, readTable := range readTables {
+			readTable.GrantReadData(function)
+		}
+		eventBus.GrantPutEventsTo(function, nil)
+		return function
 	}
 
-	function := awslambda.NewFunction(stack, jsii.String(props.ServiceName+"Function"), &awslambda.FunctionProps{
-		Runtime:     awslambda.Runtime_PROVIDED_AL2023(),
-		Handler:     jsii.String("main"),
-		Code:        awslambda.Code_FromAsset(jsii.String("services/"+props.ServiceName+"/lambda"), nil),
-		Environment: environment,
-		Timeout:     awscdk.Duration_Seconds(jsii.Number(30)),
-		MemorySize:  jsii.Number(256),
+	awscdk.NewCfnOutput(stack, jsii.String("TableName"), &awscdk.CfnOutputProps{
+		Value:       table.TableName(),
+		ExportName:  jsii.String(TableNameExport(props.ServiceName)),
+		Description: jsii.String("Table of the " + props.ServiceName + " service"),
 	})
 
-	table.GrantReadWriteData(function)
+	function := newFunction("appsync")
 	api.GrantMutation(function, jsii.String("*"))
+	cursorSecret.GrantRead(function, nil)
 
+	// A stream batch is bisected on error until the failing record is
+	// isolated; its position is sent to the stream DLQ once retries run out,
+	// so redrive can read it back from the stream.
+	streamFunction := newFunction("stream")
 	streamEventSource := awslambdaeventsources.NewDynamoEventSource(table, &awslambdaeventsources.DynamoEventSourceProps{
-		StartingPosition: awslambda.StartingPosition_LATEST,
-		BatchSize:        jsii.Number(1),
-		RetryAttempts:    jsii.Number(3),
+		StartingPosition:        awslambda.StartingPosition_LATEST,
+		BatchSize:               jsii.Number(10),
+		RetryAttempts:           jsii.Number(3),
+		BisectBatchOnError:      jsii.Bool(true),
+		ReportBatchItemFailures: jsii.Bool(true),
+		OnFailure:               awslambdaeventsources.NewSqsDlq(NewDeadLetterQueue(stack, props.ServiceName+"-stream")),
 	})
 
-	function.AddEventSource(streamEventSource)
+	streamFunction.AddEventSource(streamEventSource)
+
+	if len(props.Subscriptions) > 0 {
+		// Events EventBridge cannot deliver and events the handler keeps
+		// failing on both end up in the events DLQ.
+		eventsDlq := NewDeadLetterQueue(stack, props.ServiceName+"-events")
+		eventFunction := newFunction("eventbridge")
+		eventFunction.ConfigureAsyncInvoke(&awslambda.EventInvokeConfigOptions{
+			OnFailure:     awslambdadestinations.NewSqsDestination(eventsDlq),
+			RetryAttempts: jsii.Number(2),
+		})
+		for _, subscription := range props.Subscriptions {
+			// The detail types keep apart the rules of subscriptions to the
+			// same source.
+			ruleID := props.ServiceName + pascalCase(subscription.Source)
+			for _, detailType := range subscription.DetailTypes {
+				ruleID += pascalCase(strings.ToLower(detailType))
+			}
+			rule := awsevents.NewRule(stack, jsii.String(ruleID+"Rule"), &awsevents.RuleProps{
+				EventBus: eventBus,
+				EventPattern: &awsevents.EventPattern{
+					Source:     jsii.Strings(subscription.Source),
+					DetailType: jsii.Strings(subscription.DetailTypes...),
+				},
+			})
+			rule.AddTarget(awseventstargets.NewLambdaFunction(eventFunction, &awseventstargets.LambdaFunctionProps{
+				DeadLetterQueue: eventsDlq,
+				RetryAttempts:   jsii.Number(8),
+				MaxEventAge:     awscdk.Duration_Hours(jsii.Number(24)),
+			}))
+		}
+	}
+
+	if props.SagaTasks {
+		sagaFunction := newFunction("saga")
+		awscdk.NewCfnOutput(stack, jsii.String("SagaFunctionArn"), &awscdk.CfnOutputProps{
+			Value:       sagaFunction.FunctionArn(),
+			ExportName:  jsii.String(SagaFunctionArnExport(props.ServiceName)),
+			Description: jsii.String("Function running the " + props.ServiceName + " saga tasks"),
+		})
+	}
 
 	lambdaDataSource := api.AddLambdaDataSource(jsii.String(props.ServiceName+"LambdaDataSource"), function, nil)
+	noneDataSource := api.AddNoneDataSource(jsii.String(props.ServiceName+"NoneDataSource"), nil)
 
-	switch props.ServiceName {
-	case "inventory":
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"QueryResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("Query"),
-				FieldName: jsii.String("inventory"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"InventoryQueriesResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("InventoryQueries"),
-				FieldName: jsii.String("getItem"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"InventoryQueriesListResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("InventoryQueries"),
-				FieldName: jsii.String("listItems"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"MutationResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("Mutation"),
-				FieldName: jsii.String("inventory"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"InventoryMutationsResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("InventoryMutations"),
-				FieldName: jsii.String("createItem"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"InventoryMutationsUpdateResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("InventoryMutations"),
-				FieldName: jsii.String("updateItem"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"InventoryMutationsDeleteResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("InventoryMutations"),
-				FieldName: jsii.String("deleteItem"),
-			},
-		)
-	case "orders":
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"QueryResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("Query"),
-				FieldName: jsii.String("orders"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"OrderQueriesResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("OrderQueries"),
-				FieldName: jsii.String("getOrder"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"OrderQueriesListResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("OrderQueries"),
-				FieldName: jsii.String("listOrders"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"MutationResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("Mutation"),
-				FieldName: jsii.String("orders"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"OrderMutationsResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("OrderMutations"),
-				FieldName: jsii.String("createOrder"),
-			},
-		)
-		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"OrderMutationsUpdateResolver"),
-			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("OrderMutations"),
-				FieldName: jsii.String("updateOrderStatus"),
-			},
-		)
+	fields, err := props.Schema.Claim(props.ServiceName, props.GraphqlTypes)
+	if err != nil {
+		panic(err)
+	}
+	namespaces := make(map[string]bool, len(props.GraphqlTypes))
+	for _, name := range props.GraphqlTypes {
+		namespaces[name] = true
+	}
+	for _, field := range fields {
+		// A root field returning a namespace type (e.g. Query.inventory)
+		// resolves to an empty object whose fields the function resolves.
+		if namespaces[field.ReturnType] {
+			noneDataSource.CreateResolver(
+				jsii.String(props.ServiceName+field.TypeName+pascalCase(field.Name)+"Resolver"),
+				&awsappsync.BaseResolverProps{
+					TypeName:                jsii.String(field.TypeName),
+					FieldName:               jsii.String(field.Name),
+					RequestMappingTemplate:  awsappsync.MappingTemplate_FromString(jsii.String(namespaceRequestTemplate)),
+					ResponseMappingTemplate: awsappsync.MappingTemplate_FromString(jsii.String("$util.toJson($ctx.result)")),
+				},
+			)
+			continue
+		}
 		lambdaDataSource.CreateResolver(
-			jsii.String(props.ServiceName+"OrderMutationsCancelResolver"),
+			jsii.String(props.ServiceName+field.TypeName+pascalCase(field.Name)+"Resolver"),
 			&awsappsync.BaseResolverProps{
-				TypeName:  jsii.String("OrderMutations"),
-				FieldName: jsii.String("cancelOrder"),
+				TypeName:                jsii.String(field.TypeName),
+				FieldName:               jsii.String(field.Name),
+				RequestMappingTemplate:  awsappsync.MappingTemplate_FromString(jsii.String(resolverRequestTemplate)),
+				ResponseMappingTemplate: awsappsync.MappingTemplate_FromString(jsii.String(resolverResponseTemplate)),
 			},
 		)
 	}
 
 	return stack
 }
+
+// resolverRequestTemplate invokes the function with the same event a
+// direct Lambda resolver receives.
+const resolverRequestTemplate = `{
+  "version": "2018-05-29",
+  "operation": "Invoke",
+  "payload": {
+    "fieldName": $util.toJson($ctx.info.fieldName),
+    "arguments": $util.toJson($ctx.arguments),
+    "identity": $util.toJson($ctx.identity),
+    "source": $util.toJson($ctx.source),
+    "request": $util.toJson($ctx.request),
+    "prev": $util.toJson($ctx.prev)
+  }
+}`
+
+// namespaceRequestTemplate makes a NONE data source return an empty object.
+const namespaceRequestTemplate = `{
+  "version": "2018-05-29",
+  "payload": {}
+}`
+
+// resolverResponseTemplate raises the error of an apperror.Response with
+// its kind as errorType and its info as errorInfo, and otherwise returns
+// its data.
+const resolverResponseTemplate = `#if($ctx.error)
+  $util.error($ctx.error.message, $ctx.error.type)
+#end
+#if($ctx.result.error)
+  $util.error($ctx.result.error.message, $ctx.result.error.type, null, $ctx.result.error.info)
+#end
+$util.toJson($ctx.result.data)`
+
+// NewDeadLetterQueue creates the queue named erp-<name>-dlq collecting
+// the failures of one asynchronous path. Messages are kept for the
+// maximum of 14 days to leave time for a redrive.
+func NewDeadLetterQueue(scope constructs.Construct, name string) awssqs.Queue {
+	return awssqs.NewQueue(scope, jsii.String(pascalCase(name)+"Dlq"), &awssqs.QueueProps{
+		QueueName:       jsii.String("erp-" + name + "-dlq"),
+		RetentionPeriod: awscdk.Duration_Days(jsii.Number(14)),
+		Encryption:      awssqs.QueueEncryption_SQS_MANAGED,
+	})
+}
+
+// TableNameExport is the export name of the name of a service's table.
+func TableNameExport(serviceName string) string {
+	return "Erp" + pascalCase(serviceName) + "TableName"
+}
+
+// SagaFunctionArnExport is the export name of the ARN of a service's saga
+// function.
+func SagaFunctionArnExport(serviceName string) string {
+	return "Erp" + pascalCase(serviceName) + "SagaFunctionArn"
+}
+
+// pascalCase turns names such as "getItem" or "orders.service" into
+// construct ID fragments like "GetItem" and "OrdersService".
+func pascalCase(name string) string {
+	var b strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool { return r == '.' || r == '-' || r == '_' }) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
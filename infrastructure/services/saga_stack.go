@@ -0,0 +1,150 @@
+package services
+
+import (
+	"strings"
+
+	"serp/services/shared/saga"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsstepfunctions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsstepfunctionstasks"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+type SagaStackProps struct {
+	awscdk.StackProps
+	Saga saga.Saga
+	// Trigger selects the event on erp-event-bus starting an execution. Its
+	// data must carry the saga.Input fields.
+	Trigger EventSubscription
+}
+
+// NewSagaStack deploys a saga as a state machine invoking the saga
+// functions of the services it uses. saga.Run executes the same steps
+// locally. props is required, as it names the saga.
+func NewSagaStack(scope constructs.Construct, id string, props *SagaStackProps) awscdk.Stack {
+	stack := awscdk.NewStack(scope, &id, &props.StackProps)
+
+	functions := make(map[string]awslambda.IFunction)
+	for _, service := range props.Saga.Services() {
+		functions[service] = awslambda.Function_FromFunctionArn(stack, jsii.String(pascalCase(service)+"SagaFunction"),
+			awscdk.Fn_ImportValue(jsii.String(SagaFunctionArnExport(service))))
+	}
+
+	invoke := func(name string, task saga.Task) awsstepfunctionstasks.LambdaInvoke {
+		return awsstepfunctionstasks.NewLambdaInvoke(stack, jsii.String(name), &awsstepfunctionstasks.LambdaInvokeProps{
+			LambdaFunction: functions[task.Service],
+			Payload: awsstepfunctions.TaskInput_FromObject(&map[string]interface{}{
+				"action":        task.Action,
+				"eventId":       awsstepfunctions.JsonPath_StringAt(jsii.String("$.eventId")),
+				"correlationId": awsstepfunctions.JsonPath_StringAt(jsii.String("$.correlationId")),
+				"orderId":       awsstepfunctions.JsonPath_StringAt(jsii.String("$.orderId")),
+				"items":         awsstepfunctions.JsonPath_ListAt(jsii.String("$.items")),
+			}),
+			ResultPath: awsstepfunctions.JsonPath_DISCARD(),
+		})
+	}
+
+	// Compensations run in one chain, most recent step first, ending with
+	// the abort task. A failed step enters the chain at the compensation of
+	// the latest step before it.
+	failed := awsstepfunctions.NewFail(stack, jsii.String("Failed"), &awsstepfunctions.FailProps{
+		ErrorPath: jsii.String("$.error.Error"),
+		CausePath: jsii.String("$.error.Cause"),
+	})
+	abort := invoke("Abort", props.Saga.Abort)
+	addRetries(abort, props.Saga.Failures)
+	abort.Next(failed)
+
+	// Executions receive the trigger event, whose detail is an event
+	// envelope, and start from the saga input in its data.
+	definition := awsstepfunctions.Chain_Start(awsstepfunctions.NewPass(stack, jsii.String("ReadEvent"), &awsstepfunctions.PassProps{
+		Parameters: &map[string]interface{}{
+			"eventId.$":       "$.detail.id",
+			"correlationId.$": "$.detail.correlationId",
+			"orderId.$":       "$.detail.data.orderId",
+			"items.$":         "$.detail.data.items",
+		},
+	}))
+
+	var recovery awsstepfunctions.IChainable = abort
+	for _, step := range props.Saga.Steps {
+		if step.Task == nil {
+			definition = definition.Next(awsstepfunctions.NewPass(stack, jsii.String(step.Name), nil))
+			continue
+		}
+
+		// The saga's failures are caught at once, other errors once their
+		// retries run out.
+		task := invoke(step.Name, *step.Task)
+		addRetries(task, props.Saga.Failures)
+		if len(props.Saga.Failures) > 0 {
+			task.AddCatch(recovery, &awsstepfunctions.CatchProps{
+				Errors:     jsii.Strings(props.Saga.Failures...),
+				ResultPath: jsii.String("$.error"),
+			})
+		}
+		task.AddCatch(recovery, &awsstepfunctions.CatchProps{
+			Errors:     jsii.Strings(*awsstepfunctions.Errors_ALL()),
+			ResultPath: jsii.String("$.error"),
+		})
+		definition = definition.Next(task)
+
+		if step.Compensation != nil {
+			compensation := invoke("Undo"+step.Name, *step.Compensation)
+			addRetries(compensation, props.Saga.Failures)
+			compensation.Next(recovery)
+			recovery = compensation
+		}
+	}
+
+	stateMachine := awsstepfunctions.NewStateMachine(stack, jsii.String(props.Saga.Name+"StateMachine"), &awsstepfunctions.StateMachineProps{
+		DefinitionBody: awsstepfunctions.DefinitionBody_FromChainable(definition),
+		Timeout:        awscdk.Duration_Minutes(jsii.Number(15)),
+	})
+
+	eventBus := awsevents.EventBus_FromEventBusName(stack, jsii.String(props.Saga.Name+"EventBus"), awscdk.Fn_ImportValue(jsii.String("ErpEventBusName")))
+	rule := awsevents.NewRule(stack, jsii.String(props.Saga.Name+"Rule"), &awsevents.RuleProps{
+		EventBus: eventBus,
+		EventPattern: &awsevents.EventPattern{
+			Source:     jsii.Strings(props.Trigger.Source),
+			DetailType: jsii.Strings(props.Trigger.DetailTypes...),
+		},
+	})
+	rule.AddTarget(awseventstargets.NewSfnStateMachine(stateMachine, &awseventstargets.SfnStateMachineProps{
+		DeadLetterQueue: NewDeadLetterQueue(stack, strings.ToLower(props.Saga.Name)),
+		RetryAttempts:   jsii.Number(8),
+		MaxEventAge:     awscdk.Duration_Hours(jsii.Number(24)),
+	}))
+
+	return stack
+}
+
+// addRetries retries the errors of a task that may pass on another
+// attempt, such as a throttled invocation or a transaction conflict, on
+// top of the Lambda service errors LambdaInvoke retries. The retrier with
+// no attempts keeps the saga's failures from reaching States.ALL.
+func addRetries(task awsstepfunctionstasks.LambdaInvoke, failures []string) {
+	if len(failures) > 0 {
+		task.AddRetry(&awsstepfunctions.RetryProps{
+			Errors:      jsii.Strings(failures...),
+			MaxAttempts: jsii.Number(0),
+		})
+	}
+	task.AddRetry(&awsstepfunctions.RetryProps{
+		Errors:      jsii.Strings("Lambda.TooManyRequestsException"),
+		Interval:    awscdk.Duration_Seconds(jsii.Number(2)),
+		MaxAttempts: jsii.Number(6),
+		BackoffRate: jsii.Number(2),
+	})
+	task.AddRetry(&awsstepfunctions.RetryProps{
+		Errors:      jsii.Strings(*awsstepfunctions.Errors_ALL()),
+		Interval:    awscdk.Duration_Seconds(jsii.Number(1)),
+		MaxAttempts: jsii.Number(3),
+		BackoffRate: jsii.Number(2),
+	})
+}
@@ -43,12 +43,29 @@ func NewSharedStack(scope constructs.Construct, id string, props *SharedStackPro
 		EventBusName: jsii.String("erp-event-bus"),
 	})
 
+	// Every event published to the bus is archived so it can be replayed
+	// after a faulty consumer is fixed.
+	archive := eventBus.Archive(jsii.String("ErpEventArchive"), &awsevents.BaseArchiveProps{
+		ArchiveName: jsii.String("erp-event-archive"),
+		Description: jsii.String("Every event published to erp-event-bus"),
+		EventPattern: &awsevents.EventPattern{
+			Account: jsii.Strings(*stack.Account()),
+		},
+		Retention: awscdk.Duration_Days(jsii.Number(90)),
+	})
+
 	awscdk.NewCfnOutput(stack, jsii.String("ErpEventBusName"), &awscdk.CfnOutputProps{
 		Value:       eventBus.EventBusName(),
 		Description: jsii.String("The name of the ERP EventBus"),
 		ExportName:  jsii.String("ErpEventBusName"),
 	})
 
+	awscdk.NewCfnOutput(stack, jsii.String("ErpEventArchiveArn"), &awscdk.CfnOutputProps{
+		Value:       archive.ArchiveArn(),
+		Description: jsii.String("The ARN of the archive of the ERP EventBus"),
+		ExportName:  jsii.String("ErpEventArchiveArn"),
+	})
+
 	api := awsappsync.NewGraphqlApi(stack, jsii.String("ErpApi"), &awsappsync.GraphqlApiProps{
 		Name:   jsii.String("ErpApi"),
 		Schema: awsappsync.SchemaFile_FromAsset(jsii.String("schema.graphql")),
@@ -2,8 +2,10 @@ package main
 
 import (
 	"os"
+	"serp/infrastructure/graphql"
 	"serp/infrastructure/services"
 	"serp/infrastructure/shared"
+	"serp/services/shared/saga"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 
@@ -30,6 +32,11 @@ func main() {
 
 	app := awscdk.NewApp(nil)
 
+	schema, err := graphql.ParseFile("schema.graphql")
+	if err != nil {
+		panic(err)
+	}
+
 	// Create shared infrastructure stack
 	shared.NewSharedStack(app, "ErpSharedStack", &shared.SharedStackProps{
 		StackProps: awscdk.StackProps{
@@ -38,7 +45,7 @@ func main() {
 	})
 
 	// Services
-	services.NewMicroserviceStack(app, "InventoryServiceStack", &services.MicroserviceStackProps{
+	inventoryStack := services.NewMicroserviceStack(app, "InventoryServiceStack", &services.MicroserviceStackProps{
 		StackProps: awscdk.StackProps{
 			Env: env(),
 		},
@@ -47,9 +54,19 @@ func main() {
 		SecurityGroupId: awscdk.Fn_ImportValue(jsii.String("ErpLambdaSecurityGroupId")),
 		LambdaRoleArn:   awscdk.Fn_ImportValue(jsii.String("ErpLambdaRoleArn")),
 		GraphqlApiId:    awscdk.Fn_ImportValue(jsii.String("ErpGraphqlApiId")),
+		Schema:          schema,
+		GraphqlTypes:    []string{"InventoryQueries", "InventoryMutations"},
+		Subscriptions: []services.EventSubscription{
+			{Source: "orders.service", DetailTypes: []string{"ORDER_CANCELLED"}},
+		},
+		SagaTasks: true,
+		Indexes: []services.TableIndex{
+			{Name: "SkuIndex", PartitionKey: "sku"},
+			{Name: "CategoryIndex", PartitionKey: "category", SortKey: "name"},
+		},
 	})
 
-	services.NewMicroserviceStack(app, "OrdersServiceStack", &services.MicroserviceStackProps{
+	ordersStack := services.NewMicroserviceStack(app, "OrdersServiceStack", &services.MicroserviceStackProps{
 		StackProps: awscdk.StackProps{
 			Env: env(),
 		},
@@ -58,7 +75,37 @@ func main() {
 		SecurityGroupId: awscdk.Fn_ImportValue(jsii.String("ErpLambdaSecurityGroupId")),
 		LambdaRoleArn:   awscdk.Fn_ImportValue(jsii.String("ErpLambdaRoleArn")),
 		GraphqlApiId:    awscdk.Fn_ImportValue(jsii.String("ErpGraphqlApiId")),
+		Schema:          schema,
+		GraphqlTypes:    []string{"OrderQueries", "OrderMutations"},
+		Subscriptions: []services.EventSubscription{
+			{Source: "inventory.service", DetailTypes: []string{"INVENTORY_UPDATED", "INSUFFICIENT_INVENTORY"}},
+		},
+		SagaTasks:     true,
+		ReadsTablesOf: []string{"inventory"},
+		Indexes: []services.TableIndex{
+			{Name: "CustomerIndex", PartitionKey: "customer_id", SortKey: "created_at"},
+			{Name: "StatusIndex", PartitionKey: "status", SortKey: "created_at"},
+		},
+	})
+
+	// Orders are priced from the inventory table
+	ordersStack.AddDependency(inventoryStack, nil)
+
+	// Order fulfilment is orchestrated across the services by a saga
+	fulfilmentStack := services.NewSagaStack(app, "FulfilmentSagaStack", &services.SagaStackProps{
+		StackProps: awscdk.StackProps{
+			Env: env(),
+		},
+		Saga:    saga.Fulfilment,
+		Trigger: services.EventSubscription{Source: "orders.service", DetailTypes: []string{"ORDER_CREATED"}},
 	})
+	fulfilmentStack.AddDependency(inventoryStack, nil)
+	fulfilmentStack.AddDependency(ordersStack, nil)
+
+	// Every root field must be resolved by some service.
+	if err := schema.Validate(); err != nil {
+		panic(err)
+	}
 
 	app.Synth(nil)
 }
@@ -0,0 +1,21 @@
+package appsync
+
+import (
+	"errors"
+
+	"serp/services/inventory/lambda/shared"
+	"serp/services/shared/apperror"
+)
+
+// classify maps the inventory errors clients can act on to an apperror
+// kind. apperror.Wrap maps the shared ones and reports the rest as
+// internal.
+func classify(err error) error {
+	switch {
+	case errors.Is(err, shared.ErrSkuInUse):
+		return apperror.Conflict("%v", err)
+	case errors.Is(err, shared.ErrItemNotFound):
+		return apperror.NotFound("%v", err)
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package appsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"serp/services/inventory/lambda/shared"
+	"serp/services/shared/apperror"
+	"serp/services/shared/arguments"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/pagination"
+
+	"github.com/google/uuid"
+)
+
+type Handler struct {
+	db      shared.ItemRepository
+	cursors *pagination.Codec
+}
+
+func NewHandler(db shared.ItemRepository, cursors *pagination.Codec) *Handler {
+	return &Handler{
+		db:      db,
+		cursors: cursors,
+	}
+}
+
+// HandleRequest resolves a field and reports a failure as an
+// apperror.Response, classified by classify.
+func (h *Handler) HandleRequest(ctx context.Context, event shared.AppSyncEvent) (apperror.Response, error) {
+	return apperror.Wrap(h.resolve, classify)(ctx, event)
+}
+
+func (h *Handler) resolve(ctx context.Context, event shared.AppSyncEvent) (interface{}, error) {
+	switch event.FieldName {
+	case "getItem":
+		id, _ := event.Arguments["id"].(string)
+		return h.getItemByID(ctx, id)
+	case "listItems":
+		return h.listItems(ctx, event.Arguments)
+	case "createItem":
+		return h.createItem(ctx, event.Arguments)
+	case "updateItem":
+		return h.updateItem(ctx, event.Arguments)
+	case "deleteItem":
+		id, _ := event.Arguments["id"].(string)
+		return h.deleteItem(ctx, id)
+	default:
+		return nil, fmt.Errorf("unknown field: %s", event.FieldName)
+	}
+}
+
+func (h *Handler) getItemByID(ctx context.Context, id string) (*shared.Item, error) {
+	return h.db.GetItem(ctx, id)
+}
+
+func (h *Handler) listItems(ctx context.Context, args map[string]interface{}) (*shared.ItemConnection, error) {
+	var input shared.ListItemsInput
+	if err := arguments.Decode(args, &input); err != nil {
+		return nil, err
+	}
+	var filter shared.ItemFilterInput
+	if input.Filter != nil {
+		filter = *input.Filter
+	}
+
+	scope := shared.ItemsScope(filter)
+	startKey, err := h.cursors.Decode(scope, input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := h.db.ListItems(ctx, filter, pagination.Limit(input.Limit), startKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nextToken, err := h.cursors.Encode(scope, page.LastKey)
+	if err != nil {
+		return nil, err
+	}
+	return &shared.ItemConnection{Items: page.Items, NextToken: nextToken}, nil
+}
+
+func (h *Handler) createItem(ctx context.Context, args map[string]any) (*shared.Item, error) {
+	var request struct {
+		Input          shared.CreateItemInput `json:"input"`
+		IdempotencyKey string                 `json:"idempotencyKey"`
+	}
+	if err := arguments.Decode(args, &request); err != nil {
+		return nil, err
+	}
+	key, err := idempotency.NewKey("createItem", request.IdempotencyKey, args["input"])
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	input := request.Input
+	item := shared.Item{
+		ID:          uuid.New().String(),
+		Sku:         input.Sku,
+		Name:        input.Name,
+		Description: input.Description,
+		Quantity:    input.Quantity,
+		UnitPrice:   input.UnitPrice,
+		Category:    input.Category,
+		CreatedAt:   now.Format(time.RFC3339),
+		UpdatedAt:   now.Format(time.RFC3339),
+	}
+
+	return h.db.CreateItem(ctx, item, key)
+}
+
+func (h *Handler) updateItem(ctx context.Context, args map[string]interface{}) (*shared.Item, error) {
+	var request struct {
+		Input shared.UpdateItemInput `json:"input"`
+	}
+	if err := arguments.Decode(args, &request); err != nil {
+		return nil, err
+	}
+	input := request.Input
+	update := shared.ItemUpdate{
+		ID:          input.ID,
+		Sku:         input.Sku.Ptr(),
+		Name:        input.Name.Ptr(),
+		Description: input.Description.Ptr(),
+		Quantity:    input.Quantity.Ptr(),
+		UnitPrice:   input.UnitPrice.Ptr(),
+		Category:    input.Category.Ptr(),
+		UpdatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+
+	return h.db.UpdateItem(ctx, update)
+}
+
+// deleteItem resolves to true, as deleteItem is a Boolean!, and fails with
+// ErrItemNotFound if there was no item to delete.
+func (h *Handler) deleteItem(ctx context.Context, id string) (bool, error) {
+	item, err := h.db.DeleteItem(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if item == nil {
+		return false, fmt.Errorf("%w: %s", shared.ErrItemNotFound, id)
+	}
+	return true, nil
+}
@@ -0,0 +1,102 @@
+package appsync
+
+import (
+	"context"
+	"testing"
+
+	"serp/services/inventory/lambda/shared"
+	"serp/services/shared/apperror"
+	"serp/services/shared/pagination"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	cursors, err := pagination.NewCodec(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(shared.NewMemory(), cursors)
+}
+
+func TestListItemsRejectsTokenOfAnotherFilter(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t)
+	for _, name := range []string{"alpha", "bravo", "charlie"} {
+		response, err := h.HandleRequest(ctx, shared.AppSyncEvent{
+			FieldName: "createItem",
+			Arguments: map[string]interface{}{
+				"input": map[string]interface{}{"sku": "SKU-" + name, "name": name, "quantity": 1, "unitPrice": 1.5, "category": "tools"},
+			},
+		})
+		if err != nil || response.Error != nil {
+			t.Fatalf("createItem failed: %v %+v", err, response.Error)
+		}
+	}
+	listItems := func(filter map[string]interface{}, nextToken interface{}) apperror.Response {
+		t.Helper()
+		response, err := h.HandleRequest(ctx, shared.AppSyncEvent{
+			FieldName: "listItems",
+			Arguments: map[string]interface{}{"filter": filter, "limit": 1, "nextToken": nextToken},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	filter := map[string]interface{}{"category": "tools", "minPrice": 1}
+	first := listItems(filter, nil)
+	if first.Error != nil {
+		t.Fatalf("listItems failed: %+v", first.Error)
+	}
+	token := first.Data.(*shared.ItemConnection).NextToken
+	if token == nil {
+		t.Fatal("first page has no nextToken")
+	}
+
+	for _, other := range []map[string]interface{}{
+		{"category": "toys", "minPrice": 1},
+		{"category": "tools", "minPrice": 2},
+	} {
+		if response := listItems(other, *token); response.Error == nil || response.Error.Type != apperror.KindValidation {
+			t.Errorf("resuming with filter %v returned %+v, want a Validation error", other, response)
+		}
+	}
+	if response := listItems(filter, *token); response.Error != nil {
+		t.Errorf("resuming with the same filter failed: %+v", response.Error)
+	}
+}
+
+func TestDeleteItemResolvesToBoolean(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t)
+	response, err := h.HandleRequest(ctx, shared.AppSyncEvent{
+		FieldName: "createItem",
+		Arguments: map[string]interface{}{
+			"input": map[string]interface{}{"sku": "SKU-1", "name": "widget", "quantity": 1, "unitPrice": 1.5, "category": "tools"},
+		},
+	})
+	if err != nil || response.Error != nil {
+		t.Fatalf("createItem failed: %v %+v", err, response.Error)
+	}
+	deleteItem := shared.AppSyncEvent{
+		FieldName: "deleteItem",
+		Arguments: map[string]interface{}{"id": response.Data.(*shared.Item).ID},
+	}
+
+	response, err = h.HandleRequest(ctx, deleteItem)
+	if err != nil || response.Error != nil {
+		t.Fatalf("deleteItem failed: %v %+v", err, response.Error)
+	}
+	if response.Data != true {
+		t.Errorf("deleteItem resolved to %#v, want true", response.Data)
+	}
+
+	response, err = h.HandleRequest(ctx, deleteItem)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Type != apperror.KindNotFound {
+		t.Errorf("deleting again returned %+v, want a NotFound error", response)
+	}
+}
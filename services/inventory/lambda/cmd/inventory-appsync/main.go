@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/inventory/lambda/appsync"
+	"serp/services/inventory/lambda/shared"
+	"serp/services/shared/bootstrap"
+	"serp/services/shared/pagination"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		cursors, err := pagination.LoadCodec(ctx, c.SecretsManager, c.Env.CursorSecretArn)
+		if err != nil {
+			return nil, err
+		}
+		handler := appsync.NewHandler(shared.NewDB(c.DynamoDB, c.Env.TableName), cursors)
+		return handler.HandleRequest, nil
+	})
+}
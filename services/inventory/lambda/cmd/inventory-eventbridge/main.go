@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/inventory/lambda/eventbridge"
+	"serp/services/inventory/lambda/shared"
+	"serp/services/inventory/lambda/stock"
+	"serp/services/shared/bootstrap"
+	"serp/services/shared/idempotency"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		db := shared.NewDB(c.DynamoDB, c.Env.TableName)
+		consumer := idempotency.NewConsumer(db, "inventory-eventbridge")
+		service := stock.NewService(db, c.EventBridge, c.Env.EventBusName, consumer)
+		handler := eventbridge.NewHandler(service)
+		return handler.HandleRequest, nil
+	})
+}
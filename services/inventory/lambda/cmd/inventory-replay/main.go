@@ -0,0 +1,37 @@
+// Command inventory-replay replays a JSONL file of EventBridge events into
+// the inventory eventbridge handler, for debugging a consumer locally. It
+// is configured like the deployed function, through TABLE_NAME and
+// EVENT_BUS_NAME, and changes the table and publishes events as the
+// function would. Flags are those of event-replay local.
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"serp/services/inventory/lambda/eventbridge"
+	"serp/services/inventory/lambda/shared"
+	"serp/services/inventory/lambda/stock"
+	"serp/services/shared/bootstrap"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/replay"
+)
+
+func main() {
+	log.SetFlags(0)
+	ctx := context.Background()
+
+	c, err := bootstrap.NewClients(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db := shared.NewDB(c.DynamoDB, c.Env.TableName)
+	consumer := idempotency.NewConsumer(db, "inventory-eventbridge")
+	service := stock.NewService(db, c.EventBridge, c.Env.EventBusName, consumer)
+	handler := eventbridge.NewHandler(service)
+
+	if err := replay.RunLocal(ctx, os.Args[1:], handler.HandleRequest); err != nil {
+		log.Fatal(err)
+	}
+}
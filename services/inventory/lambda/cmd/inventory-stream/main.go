@@ -0,0 +1,15 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/inventory/lambda/stream"
+	"serp/services/shared/bootstrap"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		handler := stream.NewHandler(c.EventBridge, c.Env.EventBusName)
+		return handler.HandleRequest, nil
+	})
+}
@@ -0,0 +1,37 @@
+package eventbridge
+
+import (
+	"context"
+	"fmt"
+
+	"serp/services/inventory/lambda/stock"
+	erpevents "serp/services/shared/events"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler returns the stock of cancelled orders. Reservations are made by
+// the fulfilment saga.
+type Handler struct {
+	stock *stock.Service
+}
+
+func NewHandler(stock *stock.Service) *Handler {
+	return &Handler{
+		stock: stock,
+	}
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	orderEvent, err := erpevents.Decode(event.Detail)
+	if err != nil {
+		return err
+	}
+
+	switch payload := orderEvent.Payload.(type) {
+	case erpevents.OrderCancelledEvent:
+		return h.stock.Restore(ctx, orderEvent, payload.OrderID)
+	default:
+		return fmt.Errorf("unexpected event type: %s", orderEvent.Type)
+	}
+}
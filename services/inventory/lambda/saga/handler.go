@@ -0,0 +1,54 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+
+	"serp/services/inventory/lambda/stock"
+	erpevents "serp/services/shared/events"
+	"serp/services/shared/saga"
+)
+
+// NotReservedError fails the reserve step when stock could not be reserved
+// for the order. Step Functions sees it as the error name.
+type NotReservedError struct {
+	OrderID string
+}
+
+func (e *NotReservedError) Error() string {
+	return fmt.Sprintf("stock not reserved for order %s", e.OrderID)
+}
+
+// Handler runs the inventory tasks of the fulfilment saga.
+type Handler struct {
+	stock *stock.Service
+}
+
+func NewHandler(stock *stock.Service) *Handler {
+	return &Handler{
+		stock: stock,
+	}
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, input saga.Input) error {
+	if input.EventID == "" || input.OrderID == "" {
+		return fmt.Errorf("saga input needs eventId and orderId")
+	}
+	cause := &erpevents.Event{ID: input.EventID, CorrelationID: input.CorrelationID}
+
+	switch input.Action {
+	case saga.ActionReserve:
+		reserved, err := h.stock.Reserve(ctx, cause, input.OrderID, input.Items)
+		if err != nil {
+			return err
+		}
+		if !reserved {
+			return &NotReservedError{OrderID: input.OrderID}
+		}
+		return nil
+	case saga.ActionRestore:
+		return h.stock.Restore(ctx, cause, input.OrderID)
+	default:
+		return fmt.Errorf("unexpected saga action: %s", input.Action)
+	}
+}
@@ -0,0 +1,179 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"serp/services/inventory/lambda/shared"
+	"serp/services/inventory/lambda/stock"
+	erpevents "serp/services/shared/events"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/saga"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+)
+
+// bus records the types of the distinct events published to it. A
+// repeated request republishes its outcome unchanged, which is not
+// recorded again.
+type bus struct {
+	types []string
+	seen  map[string]bool
+}
+
+func (b *bus) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	for _, entry := range params.Entries {
+		if detail := aws.ToString(entry.Detail); !b.seen[detail] {
+			b.seen[detail] = true
+			b.types = append(b.types, aws.ToString(entry.DetailType))
+		}
+	}
+	return &eventbridge.PutEventsOutput{}, nil
+}
+
+// invoker runs the inventory tasks with a Handler and fails the orders
+// tasks in failures.
+type invoker struct {
+	handler  *Handler
+	failures map[saga.Task]error
+}
+
+func (i *invoker) Invoke(ctx context.Context, task saga.Task, input saga.Input) error {
+	if task.Service == saga.ServiceInventory {
+		return i.handler.HandleRequest(ctx, input)
+	}
+	return i.failures[task]
+}
+
+func TestFulfilment(t *testing.T) {
+	confirm := saga.Task{Service: saga.ServiceOrders, Action: saga.ActionConfirm}
+	tests := []struct {
+		name       string
+		stock      map[string]int
+		items      []erpevents.OrderLine
+		failures   map[saga.Task]error
+		wantFailed string
+		wantStock  map[string]int
+		wantEvents []string
+	}{
+		{
+			name:       "reserves the stock of a fulfilled order",
+			stock:      map[string]int{"a": 5, "b": 1},
+			items:      []erpevents.OrderLine{{ItemID: "a", Quantity: 2}, {ItemID: "b", Quantity: 1}, {ItemID: "a", Quantity: 1}},
+			wantStock:  map[string]int{"a": 2, "b": 0},
+			wantEvents: []string{"INVENTORY_UPDATED"},
+		},
+		{
+			name:       "takes no stock when a line falls short",
+			stock:      map[string]int{"a": 5, "b": 0},
+			items:      []erpevents.OrderLine{{ItemID: "a", Quantity: 2}, {ItemID: "b", Quantity: 1}},
+			wantFailed: "ReserveStock",
+			wantStock:  map[string]int{"a": 5, "b": 0},
+			wantEvents: []string{"INSUFFICIENT_INVENTORY"},
+		},
+		{
+			name:       "restores the stock when confirmation fails",
+			stock:      map[string]int{"a": 5},
+			items:      []erpevents.OrderLine{{ItemID: "a", Quantity: 2}},
+			failures:   map[saga.Task]error{confirm: errors.New("order cancelled")},
+			wantFailed: "ConfirmOrder",
+			wantStock:  map[string]int{"a": 5},
+			wantEvents: []string{"INVENTORY_UPDATED", "INVENTORY_RESTORED"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := shared.NewMemory()
+			for itemID, quantity := range tt.stock {
+				if _, err := db.CreateItem(ctx, shared.Item{ID: itemID, Quantity: quantity}, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			events := &bus{seen: make(map[string]bool)}
+			handler := NewHandler(stock.NewService(db, events, "erp-event-bus", idempotency.NewConsumer(db, "inventory-saga")))
+			input := saga.Input{EventID: "event-1", OrderID: "order-1", Items: tt.items}
+
+			result := saga.Run(ctx, saga.Fulfilment, &invoker{handler: handler, failures: tt.failures}, input)
+			if result.Failed != tt.wantFailed {
+				t.Fatalf("failed step %q, want %q (error: %v)", result.Failed, tt.wantFailed, result.Err)
+			}
+			var notReserved *NotReservedError
+			if tt.wantFailed == "ReserveStock" && !errors.As(result.Err, &notReserved) {
+				t.Errorf("reserve failed with %v, want a *NotReservedError", result.Err)
+			}
+			checkStock(t, db, tt.wantStock)
+			if !reflect.DeepEqual(events.types, tt.wantEvents) {
+				t.Errorf("published %v, want %v", events.types, tt.wantEvents)
+			}
+
+			// Step Functions may run a task again, e.g. after a timeout; a
+			// repeated task neither adjusts stock nor publishes a new event.
+			// Restoring after a successful fulfilment, as a cancellation
+			// does, returns the stock once.
+			for _, action := range []string{saga.ActionReserve, saga.ActionRestore, saga.ActionRestore} {
+				input.Action = action
+				_ = handler.HandleRequest(ctx, input)
+			}
+			if tt.wantFailed == "" {
+				tt.wantStock = tt.stock
+				tt.wantEvents = append(tt.wantEvents, "INVENTORY_RESTORED")
+			}
+			checkStock(t, db, tt.wantStock)
+			if !reflect.DeepEqual(events.types, tt.wantEvents) {
+				t.Errorf("after repeating tasks published %v, want %v", events.types, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func checkStock(t *testing.T, db shared.ItemRepository, want map[string]int) {
+	t.Helper()
+	for itemID, quantity := range want {
+		item, err := db.GetItem(context.Background(), itemID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.Quantity != quantity {
+			t.Errorf("stock of %s is %d, want %d", itemID, item.Quantity, quantity)
+		}
+	}
+}
+
+func TestReserveAgainAfterRestore(t *testing.T) {
+	ctx := context.Background()
+	db := shared.NewMemory()
+	if _, err := db.CreateItem(ctx, shared.Item{ID: "a", Quantity: 5}, nil); err != nil {
+		t.Fatal(err)
+	}
+	events := &bus{seen: make(map[string]bool)}
+	handler := NewHandler(stock.NewService(db, events, "erp-event-bus", idempotency.NewConsumer(db, "inventory-saga")))
+	items := []erpevents.OrderLine{{ItemID: "a", Quantity: 2}}
+
+	for _, input := range []saga.Input{
+		{Action: saga.ActionReserve, EventID: "event-1", OrderID: "order-1", Items: items},
+		{Action: saga.ActionRestore, EventID: "event-2", OrderID: "order-1"},
+	} {
+		if err := handler.HandleRequest(ctx, input); err != nil {
+			t.Fatalf("%s: %v", input.Action, err)
+		}
+	}
+	published := len(events.types)
+
+	// A replayed or redriven saga input reserves the restored order under
+	// a new cause.
+	replay := saga.Input{Action: saga.ActionReserve, EventID: "event-3", OrderID: "order-1", Items: items}
+	for attempt := 0; attempt < 2; attempt++ {
+		var notReserved *NotReservedError
+		if err := handler.HandleRequest(ctx, replay); !errors.As(err, &notReserved) {
+			t.Fatalf("reserving a restored order returned %v, want a *NotReservedError", err)
+		}
+	}
+	if extra := events.types[published:]; len(extra) > 0 {
+		t.Errorf("reserving a restored order published %v", extra)
+	}
+	checkStock(t, db, map[string]int{"a": 5})
+}
@@ -0,0 +1,46 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const itemPrefix = "ITEM#"
+
+// ItemKey returns the primary key of an item record.
+func ItemKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: itemPrefix + id},
+		"SK": &types.AttributeValueMemberS{Value: itemPrefix + id},
+	}
+}
+
+// MarshalItem returns the complete record of item, keys included. Empty
+// optional strings are left out, as the SKU and category are index keys
+// and may not be empty strings.
+func MarshalItem(item Item) (map[string]types.AttributeValue, error) {
+	record, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal item %s: %v", item.ID, err)
+	}
+	for name, value := range ItemKey(item.ID) {
+		record[name] = value
+	}
+	return record, nil
+}
+
+// UnmarshalItem reads an item record, or a partial one such as an update
+// result or stream image. The ID comes from the partition key.
+func UnmarshalItem(record map[string]types.AttributeValue) (Item, error) {
+	var item Item
+	if err := attributevalue.UnmarshalMap(record, &item); err != nil {
+		return Item{}, fmt.Errorf("failed to unmarshal item: %v", err)
+	}
+	if pk, ok := record["PK"].(*types.AttributeValueMemberS); ok {
+		item.ID = strings.TrimPrefix(pk.Value, itemPrefix)
+	}
+	return item, nil
+}
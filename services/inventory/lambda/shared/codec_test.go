@@ -0,0 +1,98 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+func TestItemRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		// omitted are attributes the record must leave out.
+		omitted []string
+	}{
+		{
+			name: "every field",
+			item: Item{
+				ID:          "item-1",
+				Sku:         "SKU-1",
+				Name:        "Widget",
+				Description: "A widget",
+				Quantity:    7,
+				UnitPrice:   9.99,
+				Category:    "tools",
+				CreatedAt:   "2024-05-01T10:00:00Z",
+				UpdatedAt:   "2024-05-02T10:00:00Z",
+			},
+		},
+		{
+			name:    "empty optional fields",
+			item:    Item{ID: "item-2", Name: "Gadget", CreatedAt: "2024-05-01T10:00:00Z", UpdatedAt: "2024-05-01T10:00:00Z"},
+			omitted: []string{"sku", "description", "category"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := MarshalItem(tt.item)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(record["PK"], ItemKey(tt.item.ID)["PK"]) || !reflect.DeepEqual(record["SK"], ItemKey(tt.item.ID)["SK"]) {
+				t.Errorf("record keys %v %v, want those of item %s", record["PK"], record["SK"], tt.item.ID)
+			}
+			for _, name := range tt.omitted {
+				if _, ok := record[name]; ok {
+					t.Errorf("record has empty attribute %s", name)
+				}
+			}
+
+			got, err := UnmarshalItem(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.item {
+				t.Errorf("round trip gave %+v, want %+v", got, tt.item)
+			}
+		})
+	}
+}
+
+func TestUnmarshalPartialItem(t *testing.T) {
+	// An update result or stream image may hold only some attributes.
+	got, err := UnmarshalItem(map[string]types.AttributeValue{
+		"PK":       &types.AttributeValueMemberS{Value: "ITEM#item-1"},
+		"quantity": &types.AttributeValueMemberN{Value: "3"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Item{ID: "item-1", Quantity: 3}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestStockLinesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []StockLine
+	}{
+		{"several lines", []StockLine{{ItemID: "a", Quantity: 2}, {ItemID: "b", Quantity: 1}}},
+		{"a line without quantity", []StockLine{{ItemID: "a"}}},
+		{"no lines", []StockLine{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unmarshalStockLines(marshalStockLines(tt.lines))
+			if !reflect.DeepEqual(got, tt.lines) {
+				t.Errorf("round trip gave %+v, want %+v", got, tt.lines)
+			}
+		})
+	}
+
+	if got := unmarshalStockLines(nil); got != nil {
+		t.Errorf("a missing attribute gave %+v, want nil", got)
+	}
+}
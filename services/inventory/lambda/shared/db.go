@@ -2,37 +2,70 @@ package shared
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
 	"strconv"
+	"strings"
+	"time"
+
+	"serp/services/shared/idempotency"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 )
 
+var (
+	ErrItemNotFound      = errors.New("item not found")
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrSkuInUse          = errors.New("sku already in use")
+	ErrReservationExists = errors.New("order already has a reservation")
+	ErrNoReservation     = errors.New("order holds no reservation")
+)
+
+// InsufficientStockError reports the lines a reservation could not cover.
+// It matches ErrInsufficientStock with errors.Is.
+type InsufficientStockError struct {
+	Shortfalls []Shortfall
+}
+
+func (e *InsufficientStockError) Error() string {
+	items := make([]string, len(e.Shortfalls))
+	for i, shortfall := range e.Shortfalls {
+		items[i] = shortfall.ItemID
+	}
+	return fmt.Sprintf("%v for items %s", ErrInsufficientStock, strings.Join(items, ", "))
+}
+
+func (e *InsufficientStockError) Unwrap() error {
+	return ErrInsufficientStock
+}
+
+// DB is the ItemRepository stored in the service table, whose name is fixed
+// when the DB is created. It keeps the consumer ledger in the same table.
 type DB struct {
-	client *dynamodb.Client
+	*idempotency.TableLedger
+	client    *dynamodb.Client
+	tableName string
 }
 
-func NewDB(cfg aws.Config) *DB {
+func NewDB(client *dynamodb.Client, tableName string) *DB {
 	return &DB{
-		client: dynamodb.NewFromConfig(cfg),
+		TableLedger: idempotency.NewTableLedger(client, tableName),
+		client:      client,
+		tableName:   tableName,
 	}
 }
 
 func (db *DB) GetItem(ctx context.Context, id string) (*Item, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
-	}
+	return db.getItem(ctx, id, false)
+}
 
+func (db *DB) getItem(ctx context.Context, id string, consistent bool) (*Item, error) {
 	result, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", id)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", id)},
-		},
+		TableName:      aws.String(db.tableName),
+		Key:            ItemKey(id),
+		ConsistentRead: aws.Bool(consistent),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get item: %v", err)
@@ -41,157 +74,645 @@ func (db *DB) GetItem(ctx context.Context, id string) (*Item, error) {
 		return nil, nil
 	}
 
-	item := UnmarshalItem(result.Item)
+	item, err := UnmarshalItem(result.Item)
+	if err != nil {
+		return nil, err
+	}
 	return &item, nil
 }
 
-func (db *DB) ListItems(ctx context.Context) ([]Item, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+const (
+	SkuIndex      = "SkuIndex"
+	CategoryIndex = "CategoryIndex"
+)
+
+// ItemPage is one page of ListItems results. LastKey is nil on the last page.
+type ItemPage struct {
+	Items   []Item
+	LastKey map[string]types.AttributeValue
+}
+
+// ItemsScope identifies the query ListItems runs for filter: the index it
+// reads and the filter values. A cursor can only resume the query it was
+// issued for.
+func ItemsScope(filter ItemFilterInput) string {
+	minPrice, maxPrice := "-", "-"
+	if filter.MinPrice != nil {
+		minPrice = strconv.FormatFloat(*filter.MinPrice, 'g', -1, 64)
 	}
+	if filter.MaxPrice != nil {
+		maxPrice = strconv.FormatFloat(*filter.MaxPrice, 'g', -1, 64)
+	}
+	return fmt.Sprintf("%s %q %q %q %s %s", itemsIndex(filter), filter.Sku, filter.Name, filter.Category, minPrice, maxPrice)
+}
 
-	result, err := db.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName:        aws.String(tableName),
-		FilterExpression: aws.String("begins_with(PK, :prefix)"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":prefix": &types.AttributeValueMemberS{Value: "ITEM#"},
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan items: %v", err)
+// itemsIndex returns the index ListItems reads for filter.
+func itemsIndex(filter ItemFilterInput) string {
+	switch {
+	case filter.Sku != "":
+		return SkuIndex
+	case filter.Category != "":
+		return CategoryIndex
+	default:
+		return "table"
+	}
+}
+
+// ListItems reads one page of items matching filter. A SKU or category
+// filter is served by a Query on SkuIndex or CategoryIndex (sorted by
+// name); without either, the base table is scanned page by page. The
+// remaining criteria are applied as a filter expression, so a page may hold
+// fewer than limit items while more pages remain.
+func (db *DB) ListItems(ctx context.Context, filter ItemFilterInput, limit int32, startKey map[string]types.AttributeValue) (*ItemPage, error) {
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	var keyCondition string
+	var conditions []string
+
+	switch itemsIndex(filter) {
+	case SkuIndex:
+		names["#sku"] = "sku"
+		values[":sku"] = &types.AttributeValueMemberS{Value: filter.Sku}
+		keyCondition = "#sku = :sku"
+		if filter.Category != "" {
+			names["#category"] = "category"
+			values[":category"] = &types.AttributeValueMemberS{Value: filter.Category}
+			conditions = append(conditions, "#category = :category")
+		}
+	case CategoryIndex:
+		names["#category"] = "category"
+		values[":category"] = &types.AttributeValueMemberS{Value: filter.Category}
+		keyCondition = "#category = :category"
+	default:
+		names["#pk"] = "PK"
+		values[":prefix"] = &types.AttributeValueMemberS{Value: itemPrefix}
+		conditions = append(conditions, "begins_with(#pk, :prefix)")
+	}
+	if filter.Name != "" {
+		names["#name"] = "name"
+		values[":name"] = &types.AttributeValueMemberS{Value: filter.Name}
+		conditions = append(conditions, "contains(#name, :name)")
+	}
+	if filter.MinPrice != nil {
+		names["#unit_price"] = "unit_price"
+		values[":min_price"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(*filter.MinPrice, 'f', -1, 64)}
+		conditions = append(conditions, "#unit_price >= :min_price")
+	}
+	if filter.MaxPrice != nil {
+		names["#unit_price"] = "unit_price"
+		values[":max_price"] = &types.AttributeValueMemberN{Value: strconv.FormatFloat(*filter.MaxPrice, 'f', -1, 64)}
+		conditions = append(conditions, "#unit_price <= :max_price")
+	}
+
+	var filterExpression *string
+	if len(conditions) > 0 {
+		filterExpression = aws.String(strings.Join(conditions, " AND "))
 	}
 
-	items := make([]Item, 0, len(result.Items))
-	for _, item := range result.Items {
-		invItem := UnmarshalItem(item)
-		items = append(items, invItem)
+	var rows []map[string]types.AttributeValue
+	var lastKey map[string]types.AttributeValue
+	if keyCondition == "" {
+		result, err := db.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(db.tableName),
+			FilterExpression:          filterExpression,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			Limit:                     aws.Int32(limit),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan items: %v", err)
+		}
+		rows, lastKey = result.Items, result.LastEvaluatedKey
+	} else {
+		result, err := db.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(db.tableName),
+			IndexName:                 aws.String(itemsIndex(filter)),
+			KeyConditionExpression:    aws.String(keyCondition),
+			FilterExpression:          filterExpression,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			Limit:                     aws.Int32(limit),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query items: %v", err)
+		}
+		rows, lastKey = result.Items, result.LastEvaluatedKey
+	}
+
+	items := make([]Item, 0, len(rows))
+	for _, row := range rows {
+		item, err := UnmarshalItem(row)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 	}
 
-	return items, nil
+	return &ItemPage{Items: items, LastKey: lastKey}, nil
 }
 
-func (db *DB) CreateItem(ctx context.Context, item Item) (*Item, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+// CreateItem stores a new item together with the guard record of its SKU,
+// so it fails with ErrSkuInUse if another item has the SKU. With an
+// idempotency key the key is written in the same transaction, and a replay
+// of the request returns the item the first request created.
+func (db *DB) CreateItem(ctx context.Context, item Item, key *idempotency.Key) (*Item, error) {
+	row, err := MarshalItem(item)
+	if err != nil {
+		return nil, err
 	}
 
-	_, err := db.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item: map[string]types.AttributeValue{
-			"PK":          &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", item.ID)},
-			"SK":          &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", item.ID)},
-			"name":        &types.AttributeValueMemberS{Value: item.Name},
-			"description": &types.AttributeValueMemberS{Value: item.Description},
-			"quantity":    &types.AttributeValueMemberN{Value: strconv.Itoa(item.Quantity)},
-			"created_at":  &types.AttributeValueMemberS{Value: item.CreatedAt},
-			"updated_at":  &types.AttributeValueMemberS{Value: item.UpdatedAt},
-		},
+	var writes []types.TransactWriteItem
+	if key != nil {
+		writes = append(writes, key.Put(db.tableName, item.ID, time.Now()))
+	}
+	guardIndex := len(writes)
+	if item.Sku != "" {
+		writes = append(writes, db.putSkuGuard(item.Sku, item.ID))
+	}
+	writes = append(writes, types.TransactWriteItem{Put: &types.Put{TableName: aws.String(db.tableName), Item: row}})
+
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
 	})
 	if err != nil {
+		if key != nil && idempotency.Replayed(err, 0) {
+			itemID, err := idempotency.Lookup(ctx, db.client, db.tableName, *key)
+			if err != nil {
+				return nil, err
+			}
+			return db.GetItem(ctx, itemID)
+		}
+		if item.Sku != "" {
+			if reason := cancellationReason(err, guardIndex); reason != nil {
+				return nil, skuInUse(item.Sku, reason)
+			}
+		}
 		return nil, fmt.Errorf("failed to create item: %v", err)
 	}
 
 	return &item, nil
 }
 
-func (db *DB) UpdateItem(ctx context.Context, item Item) (*Item, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+// UpdateItem sets the attributes update supplies, on the condition that the
+// item exists. A new SKU moves the item's guard record in the same
+// transaction, on the condition that the SKU the item had when it was read
+// is still its own.
+func (db *DB) UpdateItem(ctx context.Context, update ItemUpdate) (*Item, error) {
+	var current *Item
+	if update.Sku != nil {
+		var err error
+		current, err = db.getItem(ctx, update.ID, true)
+		if err != nil {
+			return nil, err
+		}
+		if current == nil {
+			return nil, fmt.Errorf("%w: %s", ErrItemNotFound, update.ID)
+		}
 	}
 
-	updateExpr := "SET #name = :name, #description = :description, #quantity = :quantity, #updated_at = :updated_at"
-	exprNames := map[string]string{
-		"#name":        "name",
-		"#description": "description",
-		"#quantity":    "quantity",
-		"#updated_at":  "updated_at",
+	item, names := update.apply(Item{ID: update.ID})
+	record, err := MarshalItem(item)
+	if err != nil {
+		return nil, err
 	}
-	exprValues := map[string]types.AttributeValue{
-		":name":        &types.AttributeValueMemberS{Value: item.Name},
-		":description": &types.AttributeValueMemberS{Value: item.Description},
-		":quantity":    &types.AttributeValueMemberN{Value: strconv.Itoa(item.Quantity)},
-		":updated_at":  &types.AttributeValueMemberS{Value: item.UpdatedAt},
+	updateExpr, exprNames, exprValues := updateExpression(record, names...)
+	exprNames["#pk"] = "PK"
+
+	if current == nil || current.Sku == *update.Sku {
+		result, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+			TableName:                 aws.String(db.tableName),
+			Key:                       ItemKey(update.ID),
+			UpdateExpression:          aws.String(updateExpr),
+			ConditionExpression:       aws.String("attribute_exists(#pk)"),
+			ExpressionAttributeNames:  exprNames,
+			ExpressionAttributeValues: exprValues,
+			ReturnValues:              types.ReturnValueAllNew,
+		})
+		if err != nil {
+			var conditionErr *types.ConditionalCheckFailedException
+			if errors.As(err, &conditionErr) {
+				return nil, fmt.Errorf("%w: %s", ErrItemNotFound, update.ID)
+			}
+			return nil, fmt.Errorf("failed to update item: %v", err)
+		}
+
+		updatedItem, err := UnmarshalItem(result.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		return &updatedItem, nil
 	}
 
-	result, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", item.ID)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", item.ID)},
-		},
-		UpdateExpression:          aws.String(updateExpr),
-		ExpressionAttributeNames:  exprNames,
-		ExpressionAttributeValues: exprValues,
-		ReturnValues:              types.ReturnValueAllNew,
+	condition := "attribute_exists(#pk) AND "
+	if current.Sku == "" {
+		condition += "attribute_not_exists(#sku)"
+	} else {
+		condition += "#sku = :current_sku"
+		exprValues[":current_sku"] = &types.AttributeValueMemberS{Value: current.Sku}
+	}
+	writes := []types.TransactWriteItem{
+		db.putSkuGuard(*update.Sku, update.ID),
+		{Update: &types.Update{
+			TableName:                 aws.String(db.tableName),
+			Key:                       ItemKey(update.ID),
+			UpdateExpression:          aws.String(updateExpr),
+			ConditionExpression:       aws.String(condition),
+			ExpressionAttributeNames:  exprNames,
+			ExpressionAttributeValues: exprValues,
+		}},
+	}
+	if current.Sku != "" {
+		writes = append(writes, db.deleteSkuGuard(current.Sku, update.ID))
+	}
+
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
 	})
 	if err != nil {
+		if reason := cancellationReason(err, 0); reason != nil {
+			return nil, skuInUse(*update.Sku, reason)
+		}
+		if cancellationReason(err, 1) != nil {
+			return nil, fmt.Errorf("failed to update item %s: it was changed or deleted concurrently", update.ID)
+		}
 		return nil, fmt.Errorf("failed to update item: %v", err)
 	}
 
-	updatedItem := UnmarshalItem(result.Attributes)
-	return &updatedItem, nil
+	updated, err := db.getItem(ctx, update.ID, true)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		return nil, fmt.Errorf("%w: %s", ErrItemNotFound, update.ID)
+	}
+	return updated, nil
 }
 
-func (db *DB) DeleteItem(ctx context.Context, id string) (*Item, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+// putSkuGuard writes the guard record holding sku for itemID, on the
+// condition that no item holds it yet.
+func (db *DB) putSkuGuard(sku, itemID string) types.TransactWriteItem {
+	guard := skuKey(sku)
+	guard["item_id"] = &types.AttributeValueMemberS{Value: itemID}
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:                           aws.String(db.tableName),
+			Item:                                guard,
+			ConditionExpression:                 aws.String("attribute_not_exists(PK)"),
+			ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+		},
+	}
+}
+
+// deleteSkuGuard removes the guard record of sku if itemID holds it. Items
+// stored before SKUs were guarded have no guard record to remove.
+func (db *DB) deleteSkuGuard(sku, itemID string) types.TransactWriteItem {
+	return types.TransactWriteItem{
+		Delete: &types.Delete{
+			TableName:           aws.String(db.tableName),
+			Key:                 skuKey(sku),
+			ConditionExpression: aws.String("attribute_not_exists(PK) OR item_id = :item_id"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":item_id": &types.AttributeValueMemberS{Value: itemID},
+			},
+		},
+	}
+}
+
+// skuInUse reports that sku is held by the item named in the guard record
+// returned by a failed putSkuGuard.
+func skuInUse(sku string, reason *types.CancellationReason) error {
+	var owner string
+	if itemID, ok := reason.Item["item_id"].(*types.AttributeValueMemberS); ok {
+		owner = itemID.Value
 	}
+	return fmt.Errorf("%w: %s is the SKU of item %s", ErrSkuInUse, sku, owner)
+}
 
-	item, err := db.GetItem(ctx, id)
+// cancellationReason returns the reason a cancelled transaction gives for
+// the write at index, if that write's condition failed.
+func cancellationReason(err error, index int) *types.CancellationReason {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) || index >= len(cancelled.CancellationReasons) {
+		return nil
+	}
+	reason := cancelled.CancellationReasons[index]
+	if aws.ToString(reason.Code) != "ConditionalCheckFailed" {
+		return nil
+	}
+	return &reason
+}
+
+// MaxStockLines is the most distinct items one reservation can touch, so
+// that they, the reservation record and a ledger entry fit the 100-item
+// limit of a transaction.
+const MaxStockLines = 98
+
+// ReserveStock removes the stock for every line of an order in one
+// transaction, so either all lines are reserved or none are. Each write
+// only succeeds while enough units remain, so concurrent reservations can
+// neither oversell nor lose an update. If any line falls short the error
+// is an *InsufficientStockError listing every short line.
+//
+// The transaction also records the reservation under the order ID. An
+// order is reserved at most once; reserving it again, even after its stock
+// was restored, fails with ErrReservationExists without touching stock or
+// writing the ledger entry.
+//
+// A non-nil processed entry is written in the same transaction, and
+// idempotency.ErrAlreadyProcessed is returned if the event it records was
+// already handled.
+func (db *DB) ReserveStock(ctx context.Context, orderID string, lines []StockLine, processed *idempotency.Processed) error {
+	lines, err := mergeStockLines(lines)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	if item == nil {
+
+	reservation := reservationKey(orderID)
+	reservation["lines"] = marshalStockLines(lines)
+	reservation["created_at"] = &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)}
+	record := types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(db.tableName),
+			Item:                reservation,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		},
+	}
+
+	err = db.adjustStock(ctx, lines, processed, record, "-", "attribute_exists(PK) AND #quantity >= :quantity")
+	if errors.Is(err, errReservationChanged) {
+		return fmt.Errorf("%w: %s", ErrReservationExists, orderID)
+	}
+	return err
+}
+
+// Reservation returns the lines reserved for an order, or nil if the order
+// was never reserved or its stock was already restored.
+func (db *DB) Reservation(ctx context.Context, orderID string) ([]StockLine, error) {
+	result, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(db.tableName),
+		Key:            reservationKey(orderID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reservation: %v", err)
+	}
+	if result.Item == nil {
+		return nil, nil
+	}
+	if _, restored := result.Item["restored_at"]; restored {
 		return nil, nil
 	}
+	return unmarshalStockLines(result.Item["lines"]), nil
+}
 
-	_, err = db.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", id)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", id)},
+// RestoreStock returns the stock of an order's reservation, as read by
+// Reservation, and marks the reservation restored in the same transaction.
+// The reservation is kept so the order cannot be reserved again, and a
+// reservation is restored at most once: restoring an order that holds none
+// fails with ErrNoReservation. Ledger handling is the same as for
+// ReserveStock.
+func (db *DB) RestoreStock(ctx context.Context, orderID string, lines []StockLine, processed *idempotency.Processed) error {
+	record := types.TransactWriteItem{
+		Update: &types.Update{
+			TableName:           aws.String(db.tableName),
+			Key:                 reservationKey(orderID),
+			UpdateExpression:    aws.String("SET restored_at = :now"),
+			ConditionExpression: aws.String("attribute_exists(PK) AND attribute_not_exists(restored_at)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+			},
 		},
+	}
+
+	err := db.adjustStock(ctx, lines, processed, record, "+", "attribute_exists(PK)")
+	if errors.Is(err, errReservationChanged) {
+		return fmt.Errorf("%w: %s", ErrNoReservation, orderID)
+	}
+	return err
+}
+
+// errReservationChanged reports that the condition on the reservation
+// record failed, so the stock adjustment was already made.
+var errReservationChanged = errors.New("reservation changed")
+
+// adjustStock applies operator and quantity to every line in a transaction
+// that starts with the optional ledger entry, followed by the reservation
+// record write.
+func (db *DB) adjustStock(ctx context.Context, lines []StockLine, processed *idempotency.Processed, record types.TransactWriteItem, operator, condition string) error {
+	var writes []types.TransactWriteItem
+	if processed != nil {
+		writes = append(writes, processed.Put(db.tableName, time.Now()))
+	}
+	recordIndex := len(writes)
+	writes = append(writes, record)
+	first := len(writes)
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, line := range lines {
+		writes = append(writes, types.TransactWriteItem{
+			Update: &types.Update{
+				TableName:           aws.String(db.tableName),
+				Key:                 ItemKey(line.ItemID),
+				UpdateExpression:    aws.String(fmt.Sprintf("SET #quantity = #quantity %s :quantity, #updated_at = :updated_at", operator)),
+				ConditionExpression: aws.String(condition),
+				ExpressionAttributeNames: map[string]string{
+					"#quantity":   "quantity",
+					"#updated_at": "updated_at",
+				},
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":quantity":   &types.AttributeValueMemberN{Value: strconv.Itoa(line.Quantity)},
+					":updated_at": &types.AttributeValueMemberS{Value: now},
+				},
+				ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+			},
+		})
+	}
+
+	_, err := db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete item: %v", err)
+		if processed != nil && idempotency.Duplicate(err) {
+			return idempotency.ErrAlreadyProcessed
+		}
+		if idempotency.Replayed(err, recordIndex) {
+			return errReservationChanged
+		}
+		if shortfalls := stockShortfalls(err, lines, first); len(shortfalls) > 0 {
+			return &InsufficientStockError{Shortfalls: shortfalls}
+		}
+		return fmt.Errorf("failed to adjust stock: %v", err)
 	}
+	return nil
+}
 
-	return item, nil
+// skuKey returns the primary key of the guard record of sku, which names
+// the item holding it.
+func skuKey(sku string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "SKU#" + sku},
+		"SK": &types.AttributeValueMemberS{Value: "SKU#" + sku},
+	}
 }
 
-func UnmarshalItem(av map[string]types.AttributeValue) Item {
-	item := Item{}
-	if v, ok := av["ID"].(*types.AttributeValueMemberS); ok {
-		item.ID = v.Value
+func reservationKey(orderID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("RESERVATION#%s", orderID)},
+		"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("RESERVATION#%s", orderID)},
 	}
-	if v, ok := av["Name"].(*types.AttributeValueMemberS); ok {
-		item.Name = v.Value
+}
+
+func marshalStockLines(lines []StockLine) types.AttributeValue {
+	list := make([]types.AttributeValue, len(lines))
+	for i, line := range lines {
+		list[i] = &types.AttributeValueMemberM{Value: map[string]types.AttributeValue{
+			"item_id":  &types.AttributeValueMemberS{Value: line.ItemID},
+			"quantity": &types.AttributeValueMemberN{Value: strconv.Itoa(line.Quantity)},
+		}}
 	}
-	if v, ok := av["Description"].(*types.AttributeValueMemberS); ok {
-		item.Description = v.Value
+	return &types.AttributeValueMemberL{Value: list}
+}
+
+func unmarshalStockLines(av types.AttributeValue) []StockLine {
+	list, _ := av.(*types.AttributeValueMemberL)
+	if list == nil {
+		return nil
 	}
-	if v, ok := av["Quantity"].(*types.AttributeValueMemberN); ok {
-		if i, err := strconv.Atoi(v.Value); err == nil {
-			item.Quantity = i
+	lines := make([]StockLine, 0, len(list.Value))
+	for _, element := range list.Value {
+		m, ok := element.(*types.AttributeValueMemberM)
+		if !ok {
+			continue
 		}
+		var line StockLine
+		if v, ok := m.Value["item_id"].(*types.AttributeValueMemberS); ok {
+			line.ItemID = v.Value
+		}
+		if v, ok := m.Value["quantity"].(*types.AttributeValueMemberN); ok {
+			line.Quantity, _ = strconv.Atoi(v.Value)
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// mergeStockLines sums lines for the same item, since a transaction may
+// only write each item once, and keeps the order of first appearance.
+func mergeStockLines(lines []StockLine) ([]StockLine, error) {
+	merged := make([]StockLine, 0, len(lines))
+	index := make(map[string]int, len(lines))
+	for _, line := range lines {
+		if line.Quantity <= 0 {
+			return nil, fmt.Errorf("quantity of item %s must be positive, got %d", line.ItemID, line.Quantity)
+		}
+		if i, ok := index[line.ItemID]; ok {
+			merged[i].Quantity += line.Quantity
+			continue
+		}
+		index[line.ItemID] = len(merged)
+		merged = append(merged, line)
+	}
+	if len(merged) > MaxStockLines {
+		return nil, fmt.Errorf("%d items in one stock adjustment, at most %d allowed", len(merged), MaxStockLines)
+	}
+	return merged, nil
+}
+
+// stockShortfalls lists the lines whose condition failed in a cancelled
+// transaction. Cancellation reasons are in the order of the writes, and
+// the writes for lines start at index first.
+func stockShortfalls(err error, lines []StockLine, first int) []Shortfall {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) {
+		return nil
 	}
-	if v, ok := av["UnitPrice"].(*types.AttributeValueMemberN); ok {
-		if f, err := strconv.ParseFloat(v.Value, 64); err == nil {
-			item.UnitPrice = f
+
+	var shortfalls []Shortfall
+	for i, reason := range cancelled.CancellationReasons {
+		line := i - first
+		if line < 0 || line >= len(lines) || aws.ToString(reason.Code) != "ConditionalCheckFailed" {
+			continue
+		}
+		shortfall := Shortfall{ItemID: lines[line].ItemID, Requested: lines[line].Quantity}
+		if reason.Item != nil {
+			// Only the quantity is needed; a record that fails to decode
+			// reports no stock available.
+			item, _ := UnmarshalItem(reason.Item)
+			shortfall.Available = item.Quantity
+		} else {
+			shortfall.Missing = true
 		}
+		shortfalls = append(shortfalls, shortfall)
+	}
+	return shortfalls
+}
+
+func (db *DB) DeleteItem(ctx context.Context, id string) (*Item, error) {
+	item, err := db.getItem(ctx, id, true)
+	if err != nil {
+		return nil, err
 	}
-	if v, ok := av["Category"].(*types.AttributeValueMemberS); ok {
-		item.Category = v.Value
+	if item == nil {
+		return nil, nil
+	}
+
+	// The item is only deleted while it still has the SKU whose guard
+	// record is removed with it.
+	remove := &types.Delete{
+		TableName:                aws.String(db.tableName),
+		Key:                      ItemKey(id),
+		ConditionExpression:      aws.String("attribute_not_exists(#sku)"),
+		ExpressionAttributeNames: map[string]string{"#sku": "sku"},
+	}
+	writes := []types.TransactWriteItem{{Delete: remove}}
+	if item.Sku != "" {
+		remove.ConditionExpression = aws.String("#sku = :sku")
+		remove.ExpressionAttributeValues = map[string]types.AttributeValue{
+			":sku": &types.AttributeValueMemberS{Value: item.Sku},
+		}
+		writes = append(writes, db.deleteSkuGuard(item.Sku, id))
+	}
+
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
+	})
+	if err != nil {
+		if cancellationReason(err, 0) != nil {
+			return nil, fmt.Errorf("failed to delete item %s: it was changed or deleted concurrently", id)
+		}
+		return nil, fmt.Errorf("failed to delete item: %v", err)
+	}
+
+	return item, nil
+}
+
+// updateExpression builds an update writing the named attributes of a
+// record: those present in it are set and the others removed.
+func updateExpression(record map[string]types.AttributeValue, names ...string) (string, map[string]string, map[string]types.AttributeValue) {
+	var set, remove []string
+	exprNames := make(map[string]string, len(names))
+	exprValues := make(map[string]types.AttributeValue, len(names))
+	for _, name := range names {
+		exprNames["#"+name] = name
+		if value, ok := record[name]; ok {
+			set = append(set, fmt.Sprintf("#%s = :%s", name, name))
+			exprValues[":"+name] = value
+		} else {
+			remove = append(remove, "#"+name)
+		}
+	}
+
+	var clauses []string
+	if len(set) > 0 {
+		clauses = append(clauses, "SET "+strings.Join(set, ", "))
 	}
-	if v, ok := av["CreatedAt"].(*types.AttributeValueMemberS); ok {
-		item.CreatedAt = v.Value
+	if len(remove) > 0 {
+		clauses = append(clauses, "REMOVE "+strings.Join(remove, ", "))
 	}
-	if v, ok := av["UpdatedAt"].(*types.AttributeValueMemberS); ok {
-		item.UpdatedAt = v.Value
+	if len(exprValues) == 0 {
+		exprValues = nil
 	}
-	return item
+	return strings.Join(clauses, " "), exprNames, exprValues
 }
@@ -0,0 +1,28 @@
+package shared_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"serp/services/inventory/lambda/shared"
+	"serp/services/inventory/lambda/shared/repotest"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// TestDB runs the checks against the table named by INVENTORY_TEST_TABLE. The
+// checks leave their records behind, so point it at a throwaway table with
+// the keys and indexes of the inventory table.
+func TestDB(t *testing.T) {
+	table := os.Getenv("INVENTORY_TEST_TABLE")
+	if table == "" {
+		t.Skip("INVENTORY_TEST_TABLE is not set")
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		t.Fatalf("failed to load AWS config: %v", err)
+	}
+	repotest.Run(t, shared.NewDB(dynamodb.NewFromConfig(cfg), table))
+}
@@ -0,0 +1,283 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"serp/services/shared/idempotency"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// Memory is the ItemRepository kept in process. It follows DB's conditional
+// writes: stock adjustments are all-or-nothing, reservations are made and
+// restored at most once, and a ledger entry is only added with the change
+// it records.
+type Memory struct {
+	mu           sync.Mutex
+	ledger       *idempotency.Memory
+	items        map[string]Item
+	reservations map[string]*memoryReservation
+}
+
+type memoryReservation struct {
+	lines    []StockLine
+	restored bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		ledger:       idempotency.NewMemory(),
+		items:        make(map[string]Item),
+		reservations: make(map[string]*memoryReservation),
+	}
+}
+
+func (m *Memory) Record(ctx context.Context, processed idempotency.Processed) error {
+	return m.ledger.Record(ctx, processed)
+}
+
+func (m *Memory) Outcome(ctx context.Context, processed idempotency.Processed) ([]byte, error) {
+	return m.ledger.Outcome(ctx, processed)
+}
+
+func (m *Memory) GetItem(ctx context.Context, id string) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.item(id), nil
+}
+
+func (m *Memory) item(id string) *Item {
+	item, ok := m.items[id]
+	if !ok {
+		return nil
+	}
+	return &item
+}
+
+// ListItems pages through the items by name for CategoryIndex, the order
+// DB reads them in, and by ID otherwise. Like DB it evaluates limit
+// items per page before filtering them.
+func (m *Memory) ListItems(ctx context.Context, filter ItemFilterInput, limit int32, startKey map[string]types.AttributeValue) (*ItemPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := itemsIndex(filter)
+	var candidates []Item
+	for _, item := range m.items {
+		switch index {
+		case SkuIndex:
+			if item.Sku != filter.Sku {
+				continue
+			}
+		case CategoryIndex:
+			if item.Category != filter.Category {
+				continue
+			}
+		}
+		candidates = append(candidates, item)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return itemBefore(index, candidates[i], candidates[j])
+	})
+
+	if startKey != nil {
+		last := Item{ID: strings.TrimPrefix(keyString(startKey, "PK"), itemPrefix), Name: keyString(startKey, "name")}
+		candidates = candidates[sort.Search(len(candidates), func(i int) bool {
+			return itemBefore(index, last, candidates[i])
+		}):]
+	}
+
+	page := &ItemPage{Items: []Item{}}
+	if limit > 0 && len(candidates) > int(limit) {
+		candidates = candidates[:limit]
+		last := candidates[len(candidates)-1]
+		page.LastKey = ItemKey(last.ID)
+		if index == CategoryIndex {
+			page.LastKey["name"] = &types.AttributeValueMemberS{Value: last.Name}
+		}
+	}
+	for _, item := range candidates {
+		if itemMatches(filter, item) {
+			page.Items = append(page.Items, item)
+		}
+	}
+	return page, nil
+}
+
+func itemBefore(index string, a, b Item) bool {
+	if index == CategoryIndex && a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.ID < b.ID
+}
+
+// itemMatches applies the criteria DB evaluates as a filter expression.
+func itemMatches(filter ItemFilterInput, item Item) bool {
+	switch {
+	case filter.Category != "" && item.Category != filter.Category:
+		return false
+	case filter.Name != "" && !strings.Contains(item.Name, filter.Name):
+		return false
+	case filter.MinPrice != nil && item.UnitPrice < *filter.MinPrice:
+		return false
+	case filter.MaxPrice != nil && item.UnitPrice > *filter.MaxPrice:
+		return false
+	}
+	return true
+}
+
+func keyString(key map[string]types.AttributeValue, name string) string {
+	if value, ok := key[name].(*types.AttributeValueMemberS); ok {
+		return value.Value
+	}
+	return ""
+}
+
+func (m *Memory) CreateItem(ctx context.Context, item Item, key *idempotency.Key) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	itemID, err := m.ledger.Claim(key, item.ID, func() error {
+		if err := m.checkSkuFree(item.Sku, item.ID); err != nil {
+			return err
+		}
+		m.items[item.ID] = item
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.item(itemID), nil
+}
+
+func (m *Memory) UpdateItem(ctx context.Context, update ItemUpdate) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.items[update.ID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrItemNotFound, update.ID)
+	}
+	if update.Sku != nil {
+		if err := m.checkSkuFree(*update.Sku, update.ID); err != nil {
+			return nil, err
+		}
+	}
+	stored, _ = update.apply(stored)
+	m.items[update.ID] = stored
+	return &stored, nil
+}
+
+// checkSkuFree fails with ErrSkuInUse if an item other than itemID has
+// sku, as the guard record DB writes would.
+func (m *Memory) checkSkuFree(sku, itemID string) error {
+	if sku == "" {
+		return nil
+	}
+	for _, item := range m.items {
+		if item.Sku == sku && item.ID != itemID {
+			return fmt.Errorf("%w: %s is the SKU of item %s", ErrSkuInUse, sku, item.ID)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) DeleteItem(ctx context.Context, id string) (*Item, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	item := m.item(id)
+	delete(m.items, id)
+	return item, nil
+}
+
+func (m *Memory) ReserveStock(ctx context.Context, orderID string, lines []StockLine, processed *idempotency.Processed) error {
+	lines, err := mergeStockLines(lines)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err = m.ledger.Apply(processed, func() error {
+		if _, ok := m.reservations[orderID]; ok {
+			return errReservationChanged
+		}
+		if err := m.adjustStock(lines, -1); err != nil {
+			return err
+		}
+		m.reservations[orderID] = &memoryReservation{lines: lines}
+		return nil
+	})
+	if errors.Is(err, errReservationChanged) {
+		return fmt.Errorf("%w: %s", ErrReservationExists, orderID)
+	}
+	return err
+}
+
+func (m *Memory) Reservation(ctx context.Context, orderID string) ([]StockLine, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	reservation, ok := m.reservations[orderID]
+	if !ok || reservation.restored {
+		return nil, nil
+	}
+	return append([]StockLine(nil), reservation.lines...), nil
+}
+
+func (m *Memory) RestoreStock(ctx context.Context, orderID string, lines []StockLine, processed *idempotency.Processed) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	err := m.ledger.Apply(processed, func() error {
+		reservation, ok := m.reservations[orderID]
+		if !ok || reservation.restored {
+			return errReservationChanged
+		}
+		if err := m.adjustStock(lines, 1); err != nil {
+			return err
+		}
+		reservation.restored = true
+		return nil
+	})
+	if errors.Is(err, errReservationChanged) {
+		return fmt.Errorf("%w: %s", ErrNoReservation, orderID)
+	}
+	return err
+}
+
+// adjustStock adds sign times each line's quantity to its item, or changes
+// nothing and reports every line that is missing or, when removing stock,
+// short.
+func (m *Memory) adjustStock(lines []StockLine, sign int) error {
+	var shortfalls []Shortfall
+	for _, line := range lines {
+		item, ok := m.items[line.ItemID]
+		switch {
+		case !ok:
+			shortfalls = append(shortfalls, Shortfall{ItemID: line.ItemID, Requested: line.Quantity, Missing: true})
+		case sign < 0 && item.Quantity < line.Quantity:
+			shortfalls = append(shortfalls, Shortfall{ItemID: line.ItemID, Requested: line.Quantity, Available: item.Quantity})
+		}
+	}
+	if len(shortfalls) > 0 {
+		return &InsufficientStockError{Shortfalls: shortfalls}
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, line := range lines {
+		item := m.items[line.ItemID]
+		item.Quantity += sign * line.Quantity
+		item.UpdatedAt = now
+		m.items[line.ItemID] = item
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package shared
+
+import (
+	"context"
+
+	"serp/services/shared/idempotency"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ItemRepository stores items, their stock and the reservations made
+// against it, together with the ledger of the consumers adjusting stock.
+// DB stores them in DynamoDB and Memory in process; both pass the checks
+// in repotest.
+type ItemRepository interface {
+	idempotency.Ledger
+
+	// GetItem returns nil if the item does not exist.
+	GetItem(ctx context.Context, id string) (*Item, error)
+	// ListItems reads one page of items matching filter, starting after
+	// startKey, the LastKey of the previous page.
+	ListItems(ctx context.Context, filter ItemFilterInput, limit int32, startKey map[string]types.AttributeValue) (*ItemPage, error)
+	// CreateItem stores a new item. It fails with ErrSkuInUse if another
+	// item has its SKU.
+	CreateItem(ctx context.Context, item Item, key *idempotency.Key) (*Item, error)
+	// UpdateItem applies update to an existing item and returns the result.
+	// It fails with ErrItemNotFound if the item does not exist and with
+	// ErrSkuInUse if another item has the SKU it sets.
+	UpdateItem(ctx context.Context, update ItemUpdate) (*Item, error)
+	// DeleteItem returns the deleted item, or nil if it did not exist.
+	DeleteItem(ctx context.Context, id string) (*Item, error)
+
+	// ReserveStock fails with ErrReservationExists if the order was
+	// reserved before, even if its stock was since restored.
+	ReserveStock(ctx context.Context, orderID string, lines []StockLine, processed *idempotency.Processed) error
+	Reservation(ctx context.Context, orderID string) ([]StockLine, error)
+	// RestoreStock fails with ErrNoReservation if the order holds no
+	// reservation.
+	RestoreStock(ctx context.Context, orderID string, lines []StockLine, processed *idempotency.Processed) error
+}
+
+var (
+	_ ItemRepository = (*DB)(nil)
+	_ ItemRepository = (*Memory)(nil)
+)
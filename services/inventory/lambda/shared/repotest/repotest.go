@@ -0,0 +1,545 @@
+// Package repotest holds the behaviour every ItemRepository must have.
+// The checks create their own items under fresh IDs, SKUs and categories,
+// so they can run against a table that is in use.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"serp/services/inventory/lambda/shared"
+	"serp/services/shared/idempotency"
+
+	"github.com/google/uuid"
+)
+
+// Run checks that repo has the behaviour of every ItemRepository, one subtest
+// per check.
+func Run(t *testing.T, repo shared.ItemRepository) {
+	ctx := context.Background()
+	for _, check := range checks {
+		check := check
+		t.Run(check.name, func(t *testing.T) {
+			if err := check.run(ctx, repo); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// check is one behaviour of a repository. run returns why the repository
+// does not have it.
+type check struct {
+	name string
+	run  func(ctx context.Context, repo shared.ItemRepository) error
+}
+
+var checks = []check{
+	{"creates and reads back an item", createAndGet},
+	{"replays a create with the same idempotency key", replayCreate},
+	{"updates only the fields supplied", update},
+	{"rejects creating an item with a SKU in use", rejectCreate},
+	{"rejects updating an unknown item or to a SKU in use", rejectUpdate},
+	{"deletes an item once", deleteItem},
+	{"pages through the items of a category by name", listCategory},
+	{"filters the item of a SKU", listSku},
+	{"reserves all lines of an order or none", reserveAllOrNothing},
+	{"reserves and restores an order at most once", reserveAndRestore},
+	{"adds a ledger entry only with its change", ledger},
+}
+
+func newItem(name string, quantity int) shared.Item {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return shared.Item{
+		ID:          uuid.New().String(),
+		Sku:         "SKU-" + uuid.New().String(),
+		Name:        name,
+		Description: "A " + name,
+		Quantity:    quantity,
+		UnitPrice:   9.99,
+		Category:    "category-" + uuid.New().String(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+}
+
+func create(ctx context.Context, repo shared.ItemRepository, items ...shared.Item) error {
+	for _, item := range items {
+		if _, err := repo.CreateItem(ctx, item, nil); err != nil {
+			return fmt.Errorf("create %s: %v", item.Name, err)
+		}
+	}
+	return nil
+}
+
+// get reads an item that must exist.
+func get(ctx context.Context, repo shared.ItemRepository, id string) (shared.Item, error) {
+	item, err := repo.GetItem(ctx, id)
+	if err != nil {
+		return shared.Item{}, fmt.Errorf("get %s: %v", id, err)
+	}
+	if item == nil {
+		return shared.Item{}, fmt.Errorf("item %s not found", id)
+	}
+	return *item, nil
+}
+
+func createAndGet(ctx context.Context, repo shared.ItemRepository) error {
+	item := newItem("widget", 3)
+	created, err := repo.CreateItem(ctx, item, nil)
+	if err != nil {
+		return err
+	}
+	if *created != item {
+		return fmt.Errorf("created %+v, want %+v", *created, item)
+	}
+	got, err := get(ctx, repo, item.ID)
+	if err != nil {
+		return err
+	}
+	if got != item {
+		return fmt.Errorf("read back %+v, want %+v", got, item)
+	}
+
+	missing, err := repo.GetItem(ctx, uuid.New().String())
+	if err != nil {
+		return err
+	}
+	if missing != nil {
+		return fmt.Errorf("got %+v for an unknown ID, want nil", *missing)
+	}
+	return nil
+}
+
+func replayCreate(ctx context.Context, repo shared.ItemRepository) error {
+	value := uuid.New().String()
+	key, err := idempotency.NewKey("createItem", value, "widget")
+	if err != nil {
+		return err
+	}
+	first := newItem("widget", 1)
+	if _, err := repo.CreateItem(ctx, first, key); err != nil {
+		return err
+	}
+
+	replay := newItem("widget", 1)
+	created, err := repo.CreateItem(ctx, replay, key)
+	if err != nil {
+		return fmt.Errorf("replay: %v", err)
+	}
+	if created == nil || created.ID != first.ID {
+		return fmt.Errorf("replay returned %+v, want item %s", created, first.ID)
+	}
+	if item, err := repo.GetItem(ctx, replay.ID); err != nil || item != nil {
+		return fmt.Errorf("replay created item %s (%v)", replay.ID, err)
+	}
+
+	reused, err := idempotency.NewKey("createItem", value, "gadget")
+	if err != nil {
+		return err
+	}
+	if _, err := repo.CreateItem(ctx, newItem("gadget", 1), reused); !errors.Is(err, idempotency.ErrKeyReused) {
+		return fmt.Errorf("reusing the key for other arguments returned %v, want %v", err, idempotency.ErrKeyReused)
+	}
+	return nil
+}
+
+func update(ctx context.Context, repo shared.ItemRepository) error {
+	item := newItem("widget", 3)
+	if err := create(ctx, repo, item); err != nil {
+		return err
+	}
+
+	name, quantity := "renamed widget", 7
+	want := item
+	want.Name, want.Quantity = name, quantity
+	want.UpdatedAt = time.Now().UTC().Add(time.Second).Format(time.RFC3339)
+	if err := updateTo(ctx, repo, want, shared.ItemUpdate{
+		ID:        item.ID,
+		Name:      &name,
+		Quantity:  &quantity,
+		UpdatedAt: want.UpdatedAt,
+	}); err != nil {
+		return err
+	}
+
+	sku, description, quantity, unitPrice, category := "SKU-"+uuid.New().String(), "", 0, 0.0, "category-"+uuid.New().String()
+	want.Sku, want.Description, want.Quantity, want.UnitPrice, want.Category = sku, description, quantity, unitPrice, category
+	want.UpdatedAt = time.Now().UTC().Add(2 * time.Second).Format(time.RFC3339)
+	if err := updateTo(ctx, repo, want, shared.ItemUpdate{
+		ID:          item.ID,
+		Sku:         &sku,
+		Description: &description,
+		Quantity:    &quantity,
+		UnitPrice:   &unitPrice,
+		Category:    &category,
+		UpdatedAt:   want.UpdatedAt,
+	}); err != nil {
+		return err
+	}
+
+	reuse := newItem("gadget", 1)
+	reuse.Sku = item.Sku
+	if err := create(ctx, repo, reuse); err != nil {
+		return fmt.Errorf("the SKU an item gave up is still taken: %v", err)
+	}
+	return nil
+}
+
+// updateTo applies update and checks that it results in want.
+func updateTo(ctx context.Context, repo shared.ItemRepository, want shared.Item, update shared.ItemUpdate) error {
+	updated, err := repo.UpdateItem(ctx, update)
+	if err != nil {
+		return err
+	}
+	if *updated != want {
+		return fmt.Errorf("updated %+v, want %+v", *updated, want)
+	}
+	got, err := get(ctx, repo, want.ID)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return fmt.Errorf("read back %+v, want %+v", got, want)
+	}
+	return nil
+}
+
+func rejectCreate(ctx context.Context, repo shared.ItemRepository) error {
+	item := newItem("widget", 3)
+	if err := create(ctx, repo, item); err != nil {
+		return err
+	}
+
+	duplicate := newItem("gadget", 1)
+	duplicate.Sku = item.Sku
+	if _, err := repo.CreateItem(ctx, duplicate, nil); !errors.Is(err, shared.ErrSkuInUse) {
+		return fmt.Errorf("creating an item with the SKU of another returned %v, want %v", err, shared.ErrSkuInUse)
+	}
+	if got, err := repo.GetItem(ctx, duplicate.ID); err != nil || got != nil {
+		return fmt.Errorf("rejected create stored %+v (%v)", got, err)
+	}
+
+	key, err := idempotency.NewKey("createItem", uuid.New().String(), "gadget")
+	if err != nil {
+		return err
+	}
+	if _, err := repo.CreateItem(ctx, duplicate, key); !errors.Is(err, shared.ErrSkuInUse) {
+		return fmt.Errorf("creating an item with the SKU of another and a key returned %v, want %v", err, shared.ErrSkuInUse)
+	}
+	duplicate.Sku = "SKU-" + uuid.New().String()
+	if _, err := repo.CreateItem(ctx, duplicate, key); err != nil {
+		return fmt.Errorf("retrying with a free SKU and the key of the rejected create: %v", err)
+	}
+	return nil
+}
+
+func rejectUpdate(ctx context.Context, repo shared.ItemRepository) error {
+	item, other := newItem("widget", 3), newItem("gadget", 1)
+	if err := create(ctx, repo, item, other); err != nil {
+		return err
+	}
+	now := time.Now().UTC().Add(time.Second).Format(time.RFC3339)
+
+	missingID, name := uuid.New().String(), "ghost"
+	_, err := repo.UpdateItem(ctx, shared.ItemUpdate{ID: missingID, Name: &name, UpdatedAt: now})
+	if !errors.Is(err, shared.ErrItemNotFound) {
+		return fmt.Errorf("updating an unknown item returned %v, want %v", err, shared.ErrItemNotFound)
+	}
+	if missing, err := repo.GetItem(ctx, missingID); err != nil || missing != nil {
+		return fmt.Errorf("updating an unknown item created %+v (%v)", missing, err)
+	}
+
+	_, err = repo.UpdateItem(ctx, shared.ItemUpdate{ID: item.ID, Sku: &other.Sku, Name: &name, UpdatedAt: now})
+	if !errors.Is(err, shared.ErrSkuInUse) {
+		return fmt.Errorf("taking the SKU of another item returned %v, want %v", err, shared.ErrSkuInUse)
+	}
+	got, err := get(ctx, repo, item.ID)
+	if err != nil {
+		return err
+	}
+	if got != item {
+		return fmt.Errorf("rejected update changed the item to %+v", got)
+	}
+
+	want := item
+	want.UpdatedAt = now
+	return updateTo(ctx, repo, want, shared.ItemUpdate{ID: item.ID, Sku: &item.Sku, UpdatedAt: now})
+}
+
+func deleteItem(ctx context.Context, repo shared.ItemRepository) error {
+	item := newItem("widget", 3)
+	if err := create(ctx, repo, item); err != nil {
+		return err
+	}
+
+	deleted, err := repo.DeleteItem(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	if deleted == nil || *deleted != item {
+		return fmt.Errorf("deleted %+v, want %+v", deleted, item)
+	}
+	again, err := repo.DeleteItem(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	if again != nil {
+		return fmt.Errorf("deleting again returned %+v, want nil", *again)
+	}
+	if got, err := repo.GetItem(ctx, item.ID); err != nil || got != nil {
+		return fmt.Errorf("deleted item still read as %+v (%v)", got, err)
+	}
+
+	reuse := newItem("gadget", 1)
+	reuse.Sku = item.Sku
+	if err := create(ctx, repo, reuse); err != nil {
+		return fmt.Errorf("the SKU of a deleted item is still taken: %v", err)
+	}
+	return nil
+}
+
+// listAll follows LastKey until the last page of filter.
+func listAll(ctx context.Context, repo shared.ItemRepository, filter shared.ItemFilterInput, limit int32) ([]shared.Item, error) {
+	var items []shared.Item
+	page, err := repo.ListItems(ctx, filter, limit, nil)
+	for pages := 1; ; pages++ {
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+		if page.LastKey == nil {
+			return items, nil
+		}
+		if pages > 100 {
+			return nil, fmt.Errorf("more than %d pages", pages)
+		}
+		page, err = repo.ListItems(ctx, filter, limit, page.LastKey)
+	}
+}
+
+func names(items []shared.Item) []string {
+	result := make([]string, len(items))
+	for i, item := range items {
+		result[i] = item.Name
+	}
+	return result
+}
+
+func listCategory(ctx context.Context, repo shared.ItemRepository) error {
+	category := "category-" + uuid.New().String()
+	var items []shared.Item
+	for i, name := range []string{"echo", "alpha", "delta", "charlie", "bravo"} {
+		item := newItem(name, 1)
+		item.Category = category
+		item.UnitPrice = float64(i + 1)
+		items = append(items, item)
+	}
+	if err := create(ctx, repo, items...); err != nil {
+		return err
+	}
+
+	listed, err := listAll(ctx, repo, shared.ItemFilterInput{Category: category}, 2)
+	if err != nil {
+		return err
+	}
+	want := []string{"alpha", "bravo", "charlie", "delta", "echo"}
+	if fmt.Sprint(names(listed)) != fmt.Sprint(want) {
+		return fmt.Errorf("listed %v, want %v", names(listed), want)
+	}
+
+	minPrice, maxPrice := 2.0, 4.0
+	listed, err = listAll(ctx, repo, shared.ItemFilterInput{Category: category, MinPrice: &minPrice, MaxPrice: &maxPrice}, 2)
+	if err != nil {
+		return err
+	}
+	want = []string{"alpha", "charlie", "delta"}
+	if fmt.Sprint(names(listed)) != fmt.Sprint(want) {
+		return fmt.Errorf("listed %v between prices %v and %v, want %v", names(listed), minPrice, maxPrice, want)
+	}
+	return nil
+}
+
+func listSku(ctx context.Context, repo shared.ItemRepository) error {
+	item := newItem("blue widget", 1)
+	if err := create(ctx, repo, item, newItem("blue widget", 1)); err != nil {
+		return err
+	}
+
+	listed, err := listAll(ctx, repo, shared.ItemFilterInput{Sku: item.Sku, Name: "blue"}, 10)
+	if err != nil {
+		return err
+	}
+	if len(listed) != 1 || listed[0] != item {
+		return fmt.Errorf("listed %+v, want only %+v", listed, item)
+	}
+
+	listed, err = listAll(ctx, repo, shared.ItemFilterInput{Sku: item.Sku, Name: "red"}, 10)
+	if err != nil {
+		return err
+	}
+	if len(listed) != 0 {
+		return fmt.Errorf("listed %+v for another name, want none", listed)
+	}
+	return nil
+}
+
+// stock returns the quantity of each item.
+func stock(ctx context.Context, repo shared.ItemRepository, items ...shared.Item) ([]int, error) {
+	quantities := make([]int, len(items))
+	for i, item := range items {
+		got, err := get(ctx, repo, item.ID)
+		if err != nil {
+			return nil, err
+		}
+		quantities[i] = got.Quantity
+	}
+	return quantities, nil
+}
+
+func wantStock(ctx context.Context, repo shared.ItemRepository, want []int, items ...shared.Item) error {
+	got, err := stock(ctx, repo, items...)
+	if err != nil {
+		return err
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("stock is %v, want %v", got, want)
+	}
+	return nil
+}
+
+func reserveAllOrNothing(ctx context.Context, repo shared.ItemRepository) error {
+	a, b := newItem("a", 5), newItem("b", 1)
+	if err := create(ctx, repo, a, b); err != nil {
+		return err
+	}
+	missing := uuid.New().String()
+	orderID := uuid.New().String()
+
+	err := repo.ReserveStock(ctx, orderID, []shared.StockLine{
+		{ItemID: a.ID, Quantity: 2},
+		{ItemID: b.ID, Quantity: 2},
+		{ItemID: missing, Quantity: 1},
+	}, nil)
+	var stockErr *shared.InsufficientStockError
+	if !errors.As(err, &stockErr) {
+		return fmt.Errorf("reserving returned %v, want an *InsufficientStockError", err)
+	}
+	want := []shared.Shortfall{
+		{ItemID: b.ID, Requested: 2, Available: 1},
+		{ItemID: missing, Requested: 1, Missing: true},
+	}
+	if fmt.Sprint(stockErr.Shortfalls) != fmt.Sprint(want) {
+		return fmt.Errorf("shortfalls %+v, want %+v", stockErr.Shortfalls, want)
+	}
+	if err := wantStock(ctx, repo, []int{5, 1}, a, b); err != nil {
+		return err
+	}
+	lines, err := repo.Reservation(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if lines != nil {
+		return fmt.Errorf("failed reservation holds %+v", lines)
+	}
+	return nil
+}
+
+func reserveAndRestore(ctx context.Context, repo shared.ItemRepository) error {
+	a, b := newItem("a", 5), newItem("b", 1)
+	if err := create(ctx, repo, a, b); err != nil {
+		return err
+	}
+	orderID := uuid.New().String()
+	lines := []shared.StockLine{{ItemID: a.ID, Quantity: 1}, {ItemID: b.ID, Quantity: 1}, {ItemID: a.ID, Quantity: 1}}
+
+	if err := repo.ReserveStock(ctx, orderID, lines, nil); err != nil {
+		return fmt.Errorf("reserve: %v", err)
+	}
+	if err := wantReservationExists(ctx, repo, orderID, lines, "again"); err != nil {
+		return err
+	}
+	if err := wantStock(ctx, repo, []int{3, 0}, a, b); err != nil {
+		return err
+	}
+	reserved, err := repo.Reservation(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	want := []shared.StockLine{{ItemID: a.ID, Quantity: 2}, {ItemID: b.ID, Quantity: 1}}
+	if fmt.Sprint(reserved) != fmt.Sprint(want) {
+		return fmt.Errorf("reservation holds %+v, want %+v", reserved, want)
+	}
+
+	if err := repo.RestoreStock(ctx, orderID, reserved, nil); err != nil {
+		return fmt.Errorf("restore: %v", err)
+	}
+	if err := repo.RestoreStock(ctx, orderID, reserved, nil); !errors.Is(err, shared.ErrNoReservation) {
+		return fmt.Errorf("restoring again returned %v, want %v", err, shared.ErrNoReservation)
+	}
+	if err := wantStock(ctx, repo, []int{5, 1}, a, b); err != nil {
+		return err
+	}
+	if reserved, err := repo.Reservation(ctx, orderID); err != nil || reserved != nil {
+		return fmt.Errorf("restored reservation holds %+v (%v)", reserved, err)
+	}
+
+	if err := wantReservationExists(ctx, repo, orderID, lines, "after restore"); err != nil {
+		return err
+	}
+	return wantStock(ctx, repo, []int{5, 1}, a, b)
+}
+
+// wantReservationExists reserves an order that already has a reservation
+// and checks that neither the stock nor the ledger entry was written.
+func wantReservationExists(ctx context.Context, repo shared.ItemRepository, orderID string, lines []shared.StockLine, when string) error {
+	processed := idempotency.Processed{Consumer: "repotest-" + uuid.New().String(), EventID: uuid.New().String(), Outcome: []byte("reserved")}
+	if err := repo.ReserveStock(ctx, orderID, lines, &processed); !errors.Is(err, shared.ErrReservationExists) {
+		return fmt.Errorf("reserving %s returned %v, want %v", when, err, shared.ErrReservationExists)
+	}
+	if err := repo.Record(ctx, processed); err != nil {
+		return fmt.Errorf("reserving %s added its ledger entry: %v", when, err)
+	}
+	return nil
+}
+
+func ledger(ctx context.Context, repo shared.ItemRepository) error {
+	item := newItem("a", 1)
+	if err := create(ctx, repo, item); err != nil {
+		return err
+	}
+	consumer := "repotest-" + uuid.New().String()
+	lines := []shared.StockLine{{ItemID: item.ID, Quantity: 1}}
+
+	reserved := idempotency.Processed{Consumer: consumer, EventID: uuid.New().String(), Outcome: []byte("reserved")}
+	if err := repo.ReserveStock(ctx, uuid.New().String(), lines, &reserved); err != nil {
+		return err
+	}
+	err := repo.ReserveStock(ctx, uuid.New().String(), lines, &reserved)
+	if !errors.Is(err, idempotency.ErrAlreadyProcessed) {
+		return fmt.Errorf("reserving for a processed event returned %v, want %v", err, idempotency.ErrAlreadyProcessed)
+	}
+	outcome, err := repo.Outcome(ctx, reserved)
+	if err != nil {
+		return err
+	}
+	if string(outcome) != string(reserved.Outcome) {
+		return fmt.Errorf("outcome %q, want %q", outcome, reserved.Outcome)
+	}
+
+	short := idempotency.Processed{Consumer: consumer, EventID: uuid.New().String()}
+	err = repo.ReserveStock(ctx, uuid.New().String(), lines, &short)
+	if !errors.Is(err, shared.ErrInsufficientStock) {
+		return fmt.Errorf("reserving without stock returned %v, want %v", err, shared.ErrInsufficientStock)
+	}
+	if err := repo.Record(ctx, short); err != nil {
+		return fmt.Errorf("failed reservation added its ledger entry: %v", err)
+	}
+	if err := repo.Record(ctx, short); !errors.Is(err, idempotency.ErrAlreadyProcessed) {
+		return fmt.Errorf("recording twice returned %v, want %v", err, idempotency.ErrAlreadyProcessed)
+	}
+	return nil
+}
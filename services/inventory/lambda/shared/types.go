@@ -1,30 +1,63 @@
 package shared
 
-import "time"
+import (
+	"serp/services/shared/arguments"
 
+	"github.com/google/uuid"
+)
+
+// Item is an inventory item. The dynamodbav tags are its record layout,
+// used by MarshalItem and UnmarshalItem; the ID is stored in the key.
 type Item struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Quantity    int     `json:"quantity"`
-	UnitPrice   float64 `json:"unitPrice"`
-	Category    string  `json:"category"`
-	CreatedAt   string  `json:"createdAt"`
-	UpdatedAt   string  `json:"updatedAt"`
+	ID          string  `json:"id" dynamodbav:"-"`
+	Sku         string  `json:"sku" dynamodbav:"sku,omitempty"`
+	Name        string  `json:"name" dynamodbav:"name"`
+	Description string  `json:"description" dynamodbav:"description,omitempty"`
+	Quantity    int     `json:"quantity" dynamodbav:"quantity"`
+	UnitPrice   float64 `json:"unitPrice" dynamodbav:"unit_price"`
+	Category    string  `json:"category" dynamodbav:"category,omitempty"`
+	CreatedAt   string  `json:"createdAt" dynamodbav:"created_at"`
+	UpdatedAt   string  `json:"updatedAt" dynamodbav:"updated_at"`
 }
 
 type ItemFilterInput struct {
-	Category string `json:"category,omitempty"`
+	Sku      string   `json:"sku,omitempty"`
+	Name     string   `json:"name,omitempty"`
+	Category string   `json:"category,omitempty"`
+	MinPrice *float64 `json:"minPrice,omitempty" validate:"min=0"`
+	MaxPrice *float64 `json:"maxPrice,omitempty" validate:"min=0"`
+}
+
+type ListItemsInput struct {
+	Filter    *ItemFilterInput `json:"filter,omitempty"`
+	Limit     *int             `json:"limit,omitempty"`
+	NextToken *string          `json:"nextToken,omitempty"`
 }
 
-type OrderEvent struct {
-	Type      string    `json:"type"`
-	OrderID   string    `json:"orderId"`
-	ItemID    string    `json:"itemId"`
-	Quantity  int       `json:"quantity"`
-	Timestamp time.Time `json:"timestamp"`
+type ItemConnection struct {
+	Items     []Item  `json:"items"`
+	NextToken *string `json:"nextToken"`
 }
 
+// StockLine is a quantity of one item to reserve or restore.
+type StockLine struct {
+	ItemID   string
+	Quantity int
+}
+
+// Shortfall is a line a reservation could not cover: the item is missing,
+// or has fewer than Requested units available.
+type Shortfall struct {
+	ItemID    string
+	Requested int
+	Available int
+	Missing   bool
+}
+
+// EventNamespace scopes the name-based IDs of events the inventory service
+// publishes.
+var EventNamespace = uuid.MustParse("04e997e0-0588-40a4-950b-b730bde08d83")
+
 type AppSyncEvent struct {
 	FieldName string                 `json:"fieldName"`
 	Arguments map[string]interface{} `json:"arguments"`
@@ -34,21 +67,70 @@ type AppSyncEvent struct {
 	Prev      interface{}            `json:"prev"`
 }
 
+// CreateItemInput and UpdateItemInput are the input arguments of the item
+// mutations. The validate tags are checked by arguments.Decode.
 type CreateItemInput struct {
-	Sku         string  `json:"sku"`
-	Name        string  `json:"name"`
+	Sku         string  `json:"sku" validate:"required"`
+	Name        string  `json:"name" validate:"required"`
 	Description string  `json:"description"`
-	Quantity    int     `json:"quantity"`
-	UnitPrice   float64 `json:"unitPrice"`
-	Category    string  `json:"category"`
+	Quantity    int     `json:"quantity" validate:"min=0"`
+	UnitPrice   float64 `json:"unitPrice" validate:"min=0"`
+	Category    string  `json:"category" validate:"required"`
 }
 
+// UpdateItemInput only changes the fields it supplies. Description may be
+// set to null to remove it; the other fields may not.
 type UpdateItemInput struct {
-	ID          string  `json:"id"`
-	Sku         string  `json:"sku,omitempty"`
-	Name        string  `json:"name,omitempty"`
-	Description string  `json:"description,omitempty"`
-	Quantity    int     `json:"quantity,omitempty"`
-	UnitPrice   float64 `json:"unitPrice,omitempty"`
-	Category    string  `json:"category,omitempty"`
+	ID          string                      `json:"id" validate:"required"`
+	Sku         arguments.Optional[string]  `json:"sku" validate:"nonblank"`
+	Name        arguments.Optional[string]  `json:"name" validate:"nonblank"`
+	Description arguments.Optional[string]  `json:"description"`
+	Quantity    arguments.Optional[int]     `json:"quantity" validate:"nonblank,min=0"`
+	UnitPrice   arguments.Optional[float64] `json:"unitPrice" validate:"nonblank,min=0"`
+	Category    arguments.Optional[string]  `json:"category" validate:"nonblank"`
+}
+
+// ItemUpdate is a change to an item. Nil fields are left as they are, and
+// an empty Description removes it.
+type ItemUpdate struct {
+	ID          string
+	Sku         *string
+	Name        *string
+	Description *string
+	Quantity    *int
+	UnitPrice   *float64
+	Category    *string
+	UpdatedAt   string
+}
+
+// apply returns item with the fields of u set, and the record attributes
+// they are stored in.
+func (u ItemUpdate) apply(item Item) (Item, []string) {
+	names := []string{"updated_at"}
+	item.UpdatedAt = u.UpdatedAt
+	if u.Sku != nil {
+		item.Sku = *u.Sku
+		names = append(names, "sku")
+	}
+	if u.Name != nil {
+		item.Name = *u.Name
+		names = append(names, "name")
+	}
+	if u.Description != nil {
+		item.Description = *u.Description
+		names = append(names, "description")
+	}
+	if u.Quantity != nil {
+		item.Quantity = *u.Quantity
+		names = append(names, "quantity")
+	}
+	if u.UnitPrice != nil {
+		item.UnitPrice = *u.UnitPrice
+		names = append(names, "unit_price")
+	}
+	if u.Category != nil {
+		item.Category = *u.Category
+		names = append(names, "category")
+	}
+	return item, names
 }
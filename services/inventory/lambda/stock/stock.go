@@ -0,0 +1,170 @@
+package stock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"serp/services/inventory/lambda/shared"
+	erpevents "serp/services/shared/events"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/streams"
+
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/google/uuid"
+)
+
+// Service reserves and restores stock for orders on behalf of a cause, the
+// event or saga step that requested it, and publishes the outcome. Each
+// request is applied once: the consumer ledger entry is written in the
+// transaction that adjusts stock, and a repeated request only republishes
+// the outcome recorded the first time.
+type Service struct {
+	db           shared.ItemRepository
+	eb           streams.Publisher
+	eventBusName string
+	consumer     *idempotency.Consumer
+}
+
+func NewService(db shared.ItemRepository, eb streams.Publisher, eventBusName string, consumer *idempotency.Consumer) *Service {
+	return &Service{
+		db:           db,
+		eb:           eb,
+		eventBusName: eventBusName,
+		consumer:     consumer,
+	}
+}
+
+// Reserve reserves every line of the order at once and reports either the
+// reservation or each line that fell short. It returns whether the order
+// holds a reservation afterwards.
+func (s *Service) Reserve(ctx context.Context, cause *erpevents.Event, orderID string, items []erpevents.OrderLine) (bool, error) {
+	key := cause.ID + "/reserve"
+	err := s.consumer.Handle(ctx, key, func(ctx context.Context) ([]byte, error) {
+		reserved, err := outcome(cause, erpevents.InventoryUpdatedEvent{
+			OrderID: orderID,
+			Items:   items,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		processed := s.consumer.Processed(key, reserved)
+		err = s.db.ReserveStock(ctx, orderID, stockLines(items), &processed)
+		var stockErr *shared.InsufficientStockError
+		switch {
+		case errors.As(err, &stockErr):
+			insufficient, err := outcome(cause, erpevents.InsufficientInventoryEvent{
+				OrderID:    orderID,
+				Shortfalls: eventShortfalls(stockErr.Shortfalls),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return insufficient, s.consumer.Record(ctx, s.consumer.Processed(key, insufficient))
+		case errors.Is(err, shared.ErrReservationExists):
+			// The order was reserved under another cause, and may since have
+			// been restored; Reservation tells which, and nothing happened
+			// to publish.
+			return nil, s.consumer.Record(ctx, s.consumer.Processed(key, nil))
+		case errors.Is(err, idempotency.ErrAlreadyProcessed):
+			return nil, err
+		case err != nil:
+			return nil, fmt.Errorf("failed to reserve stock for order %s: %v", orderID, err)
+		}
+		return reserved, nil
+	}, s.publish)
+	if err != nil {
+		return false, err
+	}
+
+	lines, err := s.db.Reservation(ctx, orderID)
+	if err != nil {
+		return false, err
+	}
+	return lines != nil, nil
+}
+
+// Restore returns the stock reserved for the order, if any is still held.
+func (s *Service) Restore(ctx context.Context, cause *erpevents.Event, orderID string) error {
+	key := cause.ID + "/restore"
+	return s.consumer.Handle(ctx, key, func(ctx context.Context) ([]byte, error) {
+		lines, err := s.db.Reservation(ctx, orderID)
+		if err != nil {
+			return nil, err
+		}
+		if lines == nil {
+			return nil, s.consumer.Record(ctx, s.consumer.Processed(key, nil))
+		}
+
+		restored, err := outcome(cause, erpevents.InventoryRestoredEvent{
+			OrderID: orderID,
+			Items:   orderLines(lines),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		processed := s.consumer.Processed(key, restored)
+		err = s.db.RestoreStock(ctx, orderID, lines, &processed)
+		switch {
+		case errors.Is(err, shared.ErrNoReservation):
+			// Restored concurrently under another cause.
+			return nil, s.consumer.Record(ctx, s.consumer.Processed(key, nil))
+		case errors.Is(err, idempotency.ErrAlreadyProcessed):
+			return nil, err
+		case err != nil:
+			return nil, fmt.Errorf("failed to restore stock for order %s: %v", orderID, err)
+		}
+		return restored, nil
+	}, s.publish)
+}
+
+func stockLines(items []erpevents.OrderLine) []shared.StockLine {
+	lines := make([]shared.StockLine, len(items))
+	for i, item := range items {
+		lines[i] = shared.StockLine{ItemID: item.ItemID, Quantity: item.Quantity}
+	}
+	return lines
+}
+
+func orderLines(lines []shared.StockLine) []erpevents.OrderLine {
+	items := make([]erpevents.OrderLine, len(lines))
+	for i, line := range lines {
+		items[i] = erpevents.OrderLine{ItemID: line.ItemID, Quantity: line.Quantity}
+	}
+	return items
+}
+
+func eventShortfalls(shortfalls []shared.Shortfall) []erpevents.Shortfall {
+	result := make([]erpevents.Shortfall, len(shortfalls))
+	for i, shortfall := range shortfalls {
+		result[i] = erpevents.Shortfall{
+			ItemID:    shortfall.ItemID,
+			Requested: shortfall.Requested,
+			Available: shortfall.Available,
+			Missing:   shortfall.Missing,
+		}
+	}
+	return result
+}
+
+// outcome encodes the event reporting how a request from cause was
+// handled. The ID is derived from the cause, so every attempt at handling
+// it produces the same ID.
+func outcome(cause *erpevents.Event, payload erpevents.Payload) ([]byte, error) {
+	id := uuid.NewSHA1(shared.EventNamespace, []byte(cause.ID+"/"+payload.EventType())).String()
+	return erpevents.Encode(erpevents.CausedBy(cause, id, payload))
+}
+
+func (s *Service) publish(ctx context.Context, outcome []byte) error {
+	event, err := erpevents.Decode(outcome)
+	if err != nil {
+		return err
+	}
+	entry, err := streams.Entry(s.eventBusName, event)
+	if err != nil {
+		return err
+	}
+	return streams.Publish(ctx, s.eb, []eventbridgetypes.PutEventsRequestEntry{entry})
+}
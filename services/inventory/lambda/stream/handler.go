@@ -0,0 +1,90 @@
+package stream
+
+import (
+	"context"
+	"strings"
+
+	"serp/services/inventory/lambda/shared"
+	erpevents "serp/services/shared/events"
+	"serp/services/shared/streams"
+
+	"github.com/aws/aws-lambda-go/events"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/google/uuid"
+)
+
+// Handler publishes a domain event for every change to an item record, so
+// writers only touch the table and the stream delivers the event.
+type Handler struct {
+	eb           streams.Publisher
+	eventBusName string
+}
+
+func NewHandler(eb streams.Publisher, eventBusName string) *Handler {
+	return &Handler{
+		eb:           eb,
+		eventBusName: eventBusName,
+	}
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	return streams.Process(ctx, event, h.publish), nil
+}
+
+func (h *Handler) publish(ctx context.Context, change streams.Change) error {
+	itemEvent, err := itemEvent(change)
+	if err != nil || itemEvent == nil {
+		return err
+	}
+
+	entry, err := streams.Entry(h.eventBusName, itemEvent)
+	if err != nil {
+		return err
+	}
+	return streams.Publish(ctx, h.eb, []eventbridgetypes.PutEventsRequestEntry{entry})
+}
+
+// itemEvent returns the event for a change to an item record. Its ID is
+// derived from the stream record, so a retried batch republishes the same
+// IDs.
+func itemEvent(change streams.Change) (*erpevents.Event, error) {
+	pk := change.Key("PK")
+	if !strings.HasPrefix(pk, "ITEM#") || change.Key("SK") != pk {
+		return nil, nil
+	}
+	itemID := strings.TrimPrefix(pk, "ITEM#")
+	id := uuid.NewSHA1(shared.EventNamespace, []byte(change.EventID)).String()
+
+	switch change.Name {
+	case streams.Insert:
+		item, err := shared.UnmarshalItem(change.NewImage)
+		if err != nil {
+			return nil, err
+		}
+		return erpevents.New(id, erpevents.ItemCreatedEvent{
+			ItemID:   itemID,
+			Name:     item.Name,
+			Quantity: item.Quantity,
+		}), nil
+	case streams.Modify:
+		previous, err := shared.UnmarshalItem(change.OldImage)
+		if err != nil {
+			return nil, err
+		}
+		item, err := shared.UnmarshalItem(change.NewImage)
+		if err != nil {
+			return nil, err
+		}
+		return erpevents.New(id, erpevents.ItemUpdatedEvent{
+			ItemID:           itemID,
+			Name:             item.Name,
+			PreviousQuantity: previous.Quantity,
+			Quantity:         item.Quantity,
+		}), nil
+	case streams.Remove:
+		return erpevents.New(id, erpevents.ItemDeletedEvent{
+			ItemID: itemID,
+		}), nil
+	}
+	return nil, nil
+}
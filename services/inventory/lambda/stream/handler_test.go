@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+
+	erpevents "serp/services/shared/events"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+)
+
+// publisher records the events put on the bus.
+type publisher struct {
+	events []*erpevents.Event
+}
+
+func (p *publisher) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	for _, entry := range params.Entries {
+		event, err := erpevents.Decode([]byte(aws.ToString(entry.Detail)))
+		if err != nil {
+			return nil, err
+		}
+		p.events = append(p.events, event)
+	}
+	return &eventbridge.PutEventsOutput{}, nil
+}
+
+func image(name string, quantity int) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		"name":     events.NewStringAttribute(name),
+		"quantity": events.NewNumberAttribute(strconv.Itoa(quantity)),
+	}
+}
+
+func record(eventID, name, pk string, oldImage, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:   eventID,
+		EventName: name,
+		Change: events.DynamoDBStreamRecord{
+			Keys: map[string]events.DynamoDBAttributeValue{
+				"PK": events.NewStringAttribute(pk),
+				"SK": events.NewStringAttribute(pk),
+			},
+			OldImage:       oldImage,
+			NewImage:       newImage,
+			SequenceNumber: eventID,
+		},
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	batch := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		record("1", "INSERT", "ITEM#a", nil, image("widget", 5)),
+		record("2", "INSERT", "SKU#W-1", nil, nil),
+		record("3", "MODIFY", "ITEM#a", image("widget", 5), image("widget", 3)),
+		record("4", "INSERT", "RESERVATION#order-1", nil, nil),
+		record("5", "INSERT", "IDEMPOTENCY#createItem#key-1", nil, nil),
+		record("6", "INSERT", "PROCESSED#inventory-saga#event-1", nil, nil),
+		record("7", "REMOVE", "ITEM#a", image("widget", 3), nil),
+	}}
+	want := []erpevents.Payload{
+		erpevents.ItemCreatedEvent{ItemID: "a", Name: "widget", Quantity: 5},
+		erpevents.ItemUpdatedEvent{ItemID: "a", Name: "widget", PreviousQuantity: 5, Quantity: 3},
+		erpevents.ItemDeletedEvent{ItemID: "a"},
+	}
+
+	bus := &publisher{}
+	handler := NewHandler(bus, "erp-event-bus")
+	if response, _ := handler.HandleRequest(context.Background(), batch); len(response.BatchItemFailures) > 0 {
+		t.Fatalf("batch failed at %v", response.BatchItemFailures)
+	}
+	var got []erpevents.Payload
+	for _, event := range bus.events {
+		got = append(got, event.Payload)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("published %+v, want %+v", got, want)
+	}
+
+	// A retried batch publishes the same event IDs, so consumers can drop
+	// the duplicates.
+	first := bus.events
+	bus.events = nil
+	if response, _ := handler.HandleRequest(context.Background(), batch); len(response.BatchItemFailures) > 0 {
+		t.Fatalf("retried batch failed at %v", response.BatchItemFailures)
+	}
+	if len(bus.events) != len(first) {
+		t.Fatalf("retried batch published %d events, want %d", len(bus.events), len(first))
+	}
+	for i, event := range bus.events {
+		if event.ID != first[i].ID {
+			t.Errorf("retried event %d has ID %s, want %s", i, event.ID, first[i].ID)
+		}
+	}
+}
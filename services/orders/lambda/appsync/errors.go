@@ -0,0 +1,36 @@
+package appsync
+
+import (
+	"errors"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/apperror"
+)
+
+// classify maps the order errors clients can act on to an apperror kind.
+// apperror.Wrap maps the shared ones and reports the rest as internal.
+func classify(err error) error {
+	var (
+		notFoundErr   *shared.OrderNotFoundError
+		transitionErr *shared.InvalidTransitionError
+		statusErr     *shared.InvalidStatusError
+	)
+	switch {
+	case errors.As(err, &notFoundErr):
+		return apperror.NotFound("%v", err).With("orderId", notFoundErr.OrderID)
+	case errors.As(err, &transitionErr):
+		return apperror.Conflict("%v", err).
+			With("orderId", transitionErr.OrderID).
+			With("from", transitionErr.From).
+			With("to", transitionErr.To)
+	case errors.As(err, &statusErr):
+		return apperror.Validation("%v", err).With("status", statusErr.Status)
+	case errors.Is(err, shared.ErrOrderExists):
+		return apperror.Conflict("%v", err)
+	case errors.Is(err, shared.ErrTooManyOrderLines),
+		errors.Is(err, shared.ErrItemNotPriced),
+		errors.Is(err, shared.ErrInvalidDateRange):
+		return apperror.Validation("%v", err)
+	}
+	return err
+}
@@ -0,0 +1,141 @@
+package appsync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/apperror"
+	"serp/services/shared/arguments"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/pagination"
+
+	"github.com/google/uuid"
+)
+
+// Handler resolves the orders fields. Mutations only write to the table;
+// the stream handler publishes the resulting events. New orders are priced
+// from prices.
+type Handler struct {
+	db      shared.OrderRepository
+	prices  shared.PriceList
+	cursors *pagination.Codec
+}
+
+func NewHandler(db shared.OrderRepository, prices shared.PriceList, cursors *pagination.Codec) *Handler {
+	return &Handler{
+		db:      db,
+		prices:  prices,
+		cursors: cursors,
+	}
+}
+
+// HandleRequest resolves a field and reports a failure as an
+// apperror.Response, classified by classify.
+func (h *Handler) HandleRequest(ctx context.Context, event shared.AppSyncEvent) (apperror.Response, error) {
+	return apperror.Wrap(h.resolve, classify)(ctx, event)
+}
+
+func (h *Handler) resolve(ctx context.Context, event shared.AppSyncEvent) (interface{}, error) {
+	switch event.FieldName {
+	case "getOrder":
+		id, _ := event.Arguments["id"].(string)
+		return h.getOrderByID(ctx, id)
+	case "listOrders":
+		return h.listOrders(ctx, event.Arguments)
+	case "createOrder":
+		return h.createOrder(ctx, event.Arguments)
+	case "updateOrderStatus":
+		return h.updateOrderStatus(ctx, event.Arguments)
+	case "cancelOrder":
+		id, _ := event.Arguments["id"].(string)
+		return h.cancelOrder(ctx, id)
+	default:
+		return nil, fmt.Errorf("unknown field: %s", event.FieldName)
+	}
+}
+
+func (h *Handler) getOrderByID(ctx context.Context, id string) (*shared.Order, error) {
+	return h.db.GetOrder(ctx, id)
+}
+
+func (h *Handler) listOrders(ctx context.Context, args map[string]interface{}) (*shared.OrderConnection, error) {
+	var input shared.ListOrdersInput
+	if err := arguments.Decode(args, &input); err != nil {
+		return nil, err
+	}
+	var filter shared.OrderFilterInput
+	if input.Filter != nil {
+		filter = *input.Filter
+	}
+
+	scope := shared.OrdersScope(filter)
+	startKey, err := h.cursors.Decode(scope, input.NextToken)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := h.db.ListOrders(ctx, filter, pagination.Limit(input.Limit), startKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nextToken, err := h.cursors.Encode(scope, page.LastKey)
+	if err != nil {
+		return nil, err
+	}
+	return &shared.OrderConnection{Items: page.Orders, NextToken: nextToken}, nil
+}
+
+func (h *Handler) createOrder(ctx context.Context, args map[string]interface{}) (*shared.Order, error) {
+	var request struct {
+		Input          shared.CreateOrderInput `json:"input"`
+		IdempotencyKey string                  `json:"idempotencyKey"`
+	}
+	if err := arguments.Decode(args, &request); err != nil {
+		return nil, err
+	}
+	key, err := idempotency.NewKey("createOrder", request.IdempotencyKey, args["input"])
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+
+	order := shared.Order{
+		ID:         uuid.New().String(),
+		CustomerID: request.Input.CustomerID,
+		Status:     shared.OrderStatusPending,
+		Items:      make([]shared.OrderItem, 0, len(request.Input.Items)),
+		CreatedAt:  now.Format(time.RFC3339),
+		UpdatedAt:  now.Format(time.RFC3339),
+	}
+	for _, item := range request.Input.Items {
+		order.Items = append(order.Items, shared.OrderItem{
+			ID:       uuid.New().String(),
+			OrderID:  order.ID,
+			ItemID:   item.ItemID,
+			Quantity: item.Quantity,
+		})
+	}
+	if err := shared.PriceOrder(ctx, h.prices, &order); err != nil {
+		return nil, err
+	}
+
+	return h.db.CreateOrder(ctx, order, key)
+}
+
+func (h *Handler) updateOrderStatus(ctx context.Context, args map[string]interface{}) (*shared.Order, error) {
+	var request struct {
+		Input shared.UpdateOrderStatusInput `json:"input"`
+	}
+	if err := arguments.Decode(args, &request); err != nil {
+		return nil, err
+	}
+
+	return h.db.UpdateOrderStatus(ctx, request.Input.OrderID, request.Input.Status, nil)
+}
+
+func (h *Handler) cancelOrder(ctx context.Context, id string) (*shared.Order, error) {
+	return h.db.UpdateOrderStatus(ctx, id, shared.OrderStatusCancelled, nil)
+}
@@ -0,0 +1,135 @@
+package appsync
+
+import (
+	"context"
+	"testing"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/apperror"
+	"serp/services/shared/pagination"
+)
+
+func newTestHandler(t *testing.T) *Handler {
+	t.Helper()
+	cursors, err := pagination.NewCodec(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	prices := shared.StaticPrices{"widget": 2.5, "gadget": 10, "sample": 0}
+	return NewHandler(shared.NewMemory(), prices, cursors)
+}
+
+func createOrder(items ...map[string]interface{}) shared.AppSyncEvent {
+	lines := make([]interface{}, len(items))
+	for i, item := range items {
+		lines[i] = item
+	}
+	return shared.AppSyncEvent{
+		FieldName: "createOrder",
+		Arguments: map[string]interface{}{
+			"input": map[string]interface{}{"customerId": "customer-1", "items": lines},
+		},
+	}
+}
+
+func TestCreateOrderPricesLines(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t)
+
+	response, err := h.HandleRequest(ctx, createOrder(
+		map[string]interface{}{"itemId": "widget", "quantity": 3},
+		map[string]interface{}{"itemId": "gadget", "quantity": 1},
+		map[string]interface{}{"itemId": "sample", "quantity": 2},
+	))
+	if err != nil || response.Error != nil {
+		t.Fatalf("createOrder failed: %v %+v", err, response.Error)
+	}
+	created := response.Data.(*shared.Order)
+	if created.TotalAmount != 17.5 {
+		t.Errorf("created order totals %v, want 17.5", created.TotalAmount)
+	}
+
+	response, err = h.HandleRequest(ctx, shared.AppSyncEvent{
+		FieldName: "getOrder",
+		Arguments: map[string]interface{}{"id": created.ID},
+	})
+	if err != nil || response.Error != nil {
+		t.Fatalf("getOrder failed: %v %+v", err, response.Error)
+	}
+	order := response.Data.(*shared.Order)
+	if order.TotalAmount != 17.5 {
+		t.Errorf("read back order totals %v, want 17.5", order.TotalAmount)
+	}
+	want := map[string]float64{"widget": 7.5, "gadget": 10, "sample": 0}
+	for _, line := range order.Items {
+		if line.TotalPrice != want[line.ItemID] {
+			t.Errorf("line of %s totals %v, want %v", line.ItemID, line.TotalPrice, want[line.ItemID])
+		}
+	}
+}
+
+func TestCreateOrderRejectsUnpricedLines(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t)
+
+	response, err := h.HandleRequest(ctx, createOrder(
+		map[string]interface{}{"itemId": "widget", "quantity": 1},
+		map[string]interface{}{"itemId": "unknown", "quantity": 1},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Error == nil || response.Error.Type != apperror.KindValidation {
+		t.Fatalf("createOrder with an unpriced item returned %+v, want a Validation error", response)
+	}
+
+	response, err = h.HandleRequest(ctx, shared.AppSyncEvent{FieldName: "listOrders", Arguments: map[string]interface{}{}})
+	if err != nil || response.Error != nil {
+		t.Fatalf("listOrders failed: %v %+v", err, response.Error)
+	}
+	if orders := response.Data.(*shared.OrderConnection).Items; len(orders) != 0 {
+		t.Errorf("rejected order was stored: %+v", orders)
+	}
+}
+
+func TestListOrdersRejectsTokenOfAnotherFilter(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler(t)
+	for i := 0; i < 2; i++ {
+		response, err := h.HandleRequest(ctx, createOrder(map[string]interface{}{"itemId": "widget", "quantity": 1}))
+		if err != nil || response.Error != nil {
+			t.Fatalf("createOrder failed: %v %+v", err, response.Error)
+		}
+	}
+	listOrders := func(customerID string, nextToken interface{}) apperror.Response {
+		t.Helper()
+		response, err := h.HandleRequest(ctx, shared.AppSyncEvent{
+			FieldName: "listOrders",
+			Arguments: map[string]interface{}{
+				"filter":    map[string]interface{}{"customerId": customerID},
+				"limit":     1,
+				"nextToken": nextToken,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	first := listOrders("customer-1", nil)
+	if first.Error != nil {
+		t.Fatalf("listOrders failed: %+v", first.Error)
+	}
+	token := first.Data.(*shared.OrderConnection).NextToken
+	if token == nil {
+		t.Fatal("first page has no nextToken")
+	}
+
+	if response := listOrders("customer-2", *token); response.Error == nil || response.Error.Type != apperror.KindValidation {
+		t.Errorf("resuming with another customer returned %+v, want a Validation error", response)
+	}
+	if response := listOrders("customer-1", *token); response.Error != nil {
+		t.Errorf("resuming with the same customer failed: %+v", response.Error)
+	}
+}
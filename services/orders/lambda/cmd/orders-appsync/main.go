@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/orders/lambda/appsync"
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/bootstrap"
+	"serp/services/shared/pagination"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		cursors, err := pagination.LoadCodec(ctx, c.SecretsManager, c.Env.CursorSecretArn)
+		if err != nil {
+			return nil, err
+		}
+		inventoryTable, err := c.Env.TableOf("inventory")
+		if err != nil {
+			return nil, err
+		}
+		prices := shared.NewInventoryPrices(c.DynamoDB, inventoryTable)
+		handler := appsync.NewHandler(shared.NewDB(c.DynamoDB, c.Env.TableName), prices, cursors)
+		return handler.HandleRequest, nil
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/orders/lambda/eventbridge"
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/bootstrap"
+	"serp/services/shared/idempotency"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		db := shared.NewDB(c.DynamoDB, c.Env.TableName)
+		consumer := idempotency.NewConsumer(db, "orders-eventbridge")
+		handler := eventbridge.NewHandler(db, consumer)
+		return handler.HandleRequest, nil
+	})
+}
@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/orders/lambda/saga"
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/bootstrap"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		handler := saga.NewHandler(shared.NewDB(c.DynamoDB, c.Env.TableName))
+		return handler.HandleRequest, nil
+	})
+}
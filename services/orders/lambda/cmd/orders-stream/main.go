@@ -0,0 +1,16 @@
+package main
+
+import (
+	"context"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/orders/lambda/stream"
+	"serp/services/shared/bootstrap"
+)
+
+func main() {
+	bootstrap.Start(func(ctx context.Context, c *bootstrap.Clients) (interface{}, error) {
+		handler := stream.NewHandler(shared.NewDB(c.DynamoDB, c.Env.TableName), c.EventBridge, c.Env.EventBusName)
+		return handler.HandleRequest, nil
+	})
+}
@@ -0,0 +1,81 @@
+package eventbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"serp/services/orders/lambda/shared"
+	erpevents "serp/services/shared/events"
+	"serp/services/shared/idempotency"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Handler moves orders on inventory outcomes, so an order reflects its
+// stock as soon as the reservation is known; the fulfilment saga confirms
+// or rejects the same orders and finds them already moved. Each event is
+// applied once: the consumer ledger entry is written in the transaction
+// that changes the order status.
+type Handler struct {
+	db       shared.OrderRepository
+	consumer *idempotency.Consumer
+}
+
+func NewHandler(db shared.OrderRepository, consumer *idempotency.Consumer) *Handler {
+	return &Handler{
+		db:       db,
+		consumer: consumer,
+	}
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	inventoryEvent, err := erpevents.Decode(event.Detail)
+	if err != nil {
+		return err
+	}
+
+	switch payload := inventoryEvent.Payload.(type) {
+	case erpevents.InventoryUpdatedEvent:
+		return h.moveOrder(ctx, inventoryEvent, payload.OrderID, shared.OrderStatusConfirmed)
+	case erpevents.InsufficientInventoryEvent:
+		return h.moveOrder(ctx, inventoryEvent, payload.OrderID, shared.OrderStatusRejected)
+	default:
+		return fmt.Errorf("unexpected event type: %s", inventoryEvent.Type)
+	}
+}
+
+// moveOrder applies the stock outcome to the order. A redelivered event is
+// a no-op. An order that has already left PENDING, for instance through a
+// customer cancellation or the saga, is left as it is, as is an order that
+// no longer exists; retrying would not change either outcome.
+func (h *Handler) moveOrder(ctx context.Context, event *erpevents.Event, orderID string, status shared.OrderStatus) error {
+	if orderID == "" {
+		return fmt.Errorf("invalid event: missing orderId")
+	}
+
+	return h.consumer.Handle(ctx, event.ID, func(ctx context.Context) ([]byte, error) {
+		processed := h.consumer.Processed(event.ID, nil)
+		_, err := h.db.UpdateOrderStatus(ctx, orderID, status, &processed)
+		return nil, ignoreStaleOutcome(orderID, err)
+	}, nil)
+}
+
+func ignoreStaleOutcome(orderID string, err error) error {
+	var transitionErr *shared.InvalidTransitionError
+	var notFoundErr *shared.OrderNotFoundError
+	switch {
+	case errors.Is(err, idempotency.ErrAlreadyProcessed):
+		return err
+	case errors.As(err, &transitionErr):
+		log.Printf("ignoring inventory outcome: %v", err)
+		return nil
+	case errors.As(err, &notFoundErr):
+		log.Printf("ignoring inventory outcome: %v", err)
+		return nil
+	case err != nil:
+		return fmt.Errorf("failed to update order %s: %v", orderID, err)
+	}
+	return nil
+}
@@ -6,12 +6,30 @@ require (
 	github.com/aws/aws-lambda-go v1.46.0
 	github.com/aws/aws-sdk-go-v2 v1.25.3
 	github.com/aws/aws-sdk-go-v2/config v1.27.7
+	github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue v1.13.9
 	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.30.4
 	github.com/aws/aws-sdk-go-v2/service/eventbridge v1.26.0
 	github.com/google/uuid v1.5.0
+	serp/services/shared/apperror v0.0.0-00010101000000-000000000000
+	serp/services/shared/arguments v0.0.0-00010101000000-000000000000
+	serp/services/shared/bootstrap v0.0.0-00010101000000-000000000000
+	serp/services/shared/events v0.0.0-00010101000000-000000000000
+	serp/services/shared/idempotency v0.0.0-00010101000000-000000000000
+	serp/services/shared/pagination v0.0.0-00010101000000-000000000000
+	serp/services/shared/saga v0.0.0-00010101000000-000000000000
+	serp/services/shared/streams v0.0.0-00010101000000-000000000000
 )
 
-replace serp/services/shared/events => ../../shared/events
+replace (
+	serp/services/shared/apperror => ../../shared/apperror
+	serp/services/shared/arguments => ../../shared/arguments
+	serp/services/shared/bootstrap => ../../shared/bootstrap
+	serp/services/shared/events => ../../shared/events
+	serp/services/shared/idempotency => ../../shared/idempotency
+	serp/services/shared/pagination => ../../shared/pagination
+	serp/services/shared/saga => ../../shared/saga
+	serp/services/shared/streams => ../../shared/streams
+)
 
 require (
 	github.com/aws/aws-sdk-go-v2/credentials v1.17.7 // indirect
@@ -20,9 +38,11 @@ require (
 	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.3 // indirect
 	github.com/aws/aws-sdk-go-v2/internal/ini v1.8.0 // indirect
 	github.com/aws/aws-sdk-go-v2/internal/v4a v1.2.6 // indirect
+	github.com/aws/aws-sdk-go-v2/service/dynamodbstreams v1.20.2 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.11.1 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/endpoint-discovery v1.9.4 // indirect
 	github.com/aws/aws-sdk-go-v2/service/internal/presigned-url v1.11.5 // indirect
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.28.2 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sso v1.20.2 // indirect
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.23.2 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.28.4 // indirect
@@ -0,0 +1,110 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/saga"
+)
+
+// progress is the path a fulfilled order takes. A task moving an order
+// along it succeeds if the order is already at or past the target, so a
+// retried task is a no-op.
+var progress = []shared.OrderStatus{
+	shared.OrderStatusPending,
+	shared.OrderStatusConfirmed,
+	shared.OrderStatusProcessing,
+	shared.OrderStatusShipped,
+	shared.OrderStatusDelivered,
+}
+
+// Handler runs the orders tasks of the fulfilment saga. Failures are
+// returned unwrapped so Step Functions sees their type name as the error.
+type Handler struct {
+	db shared.OrderRepository
+}
+
+func NewHandler(db shared.OrderRepository) *Handler {
+	return &Handler{
+		db: db,
+	}
+}
+
+func (h *Handler) HandleRequest(ctx context.Context, input saga.Input) error {
+	if input.OrderID == "" {
+		return fmt.Errorf("saga input needs orderId")
+	}
+
+	switch input.Action {
+	case saga.ActionConfirm:
+		return h.advance(ctx, input.OrderID, shared.OrderStatusConfirmed)
+	case saga.ActionAbort:
+		return h.abort(ctx, input.OrderID)
+	default:
+		return fmt.Errorf("unexpected saga action: %s", input.Action)
+	}
+}
+
+// advance moves the order to status unless it has already reached it.
+func (h *Handler) advance(ctx context.Context, orderID string, status shared.OrderStatus) error {
+	_, err := h.db.UpdateOrderStatus(ctx, orderID, status, nil)
+	var transitionErr *shared.InvalidTransitionError
+	if errors.As(err, &transitionErr) && reached(transitionErr.From, status) {
+		return nil
+	}
+	return err
+}
+
+// abort ends a failed fulfilment: an order that was never confirmed is
+// rejected and one that was is cancelled. An order that is already final
+// is left as it is.
+func (h *Handler) abort(ctx context.Context, orderID string) error {
+	order, err := h.db.GetOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+	if order == nil {
+		log.Printf("not aborting order %s: not found", orderID)
+		return nil
+	}
+
+	status := order.Status
+	// The status may change between reading and updating, so retry with the
+	// status the update was rejected for.
+	for attempt := 0; attempt < 3; attempt++ {
+		var next shared.OrderStatus
+		switch {
+		case status.CanTransitionTo(shared.OrderStatusRejected):
+			next = shared.OrderStatusRejected
+		case status.CanTransitionTo(shared.OrderStatusCancelled):
+			next = shared.OrderStatusCancelled
+		default:
+			log.Printf("not aborting order %s: already %s", orderID, status)
+			return nil
+		}
+
+		_, err := h.db.UpdateOrderStatus(ctx, orderID, next, nil)
+		var transitionErr *shared.InvalidTransitionError
+		if !errors.As(err, &transitionErr) {
+			return err
+		}
+		status = transitionErr.From
+	}
+	return fmt.Errorf("failed to abort order %s: status keeps changing", orderID)
+}
+
+func reached(current, target shared.OrderStatus) bool {
+	currentIndex, targetIndex := -1, -1
+	for i, status := range progress {
+		switch status {
+		case current:
+			currentIndex = i
+		case target:
+			targetIndex = i
+		}
+	}
+	return currentIndex >= 0 && currentIndex >= targetIndex
+}
@@ -0,0 +1,137 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/saga"
+)
+
+// invoker runs the orders tasks with a Handler and fails the inventory
+// tasks in failures.
+type invoker struct {
+	handler  *Handler
+	failures map[saga.Task]error
+}
+
+func (i *invoker) Invoke(ctx context.Context, task saga.Task, input saga.Input) error {
+	if task.Service == saga.ServiceOrders {
+		return i.handler.HandleRequest(ctx, input)
+	}
+	return i.failures[task]
+}
+
+func TestFulfilment(t *testing.T) {
+	reserve := saga.Task{Service: saga.ServiceInventory, Action: saga.ActionReserve}
+	tests := []struct {
+		name       string
+		status     []shared.OrderStatus
+		failures   map[saga.Task]error
+		wantFailed string
+		wantStatus shared.OrderStatus
+	}{
+		{
+			name:       "confirms an order with stock",
+			wantStatus: shared.OrderStatusConfirmed,
+		},
+		{
+			name:       "rejects an order without stock",
+			failures:   map[saga.Task]error{reserve: errors.New("NotReservedError")},
+			wantFailed: "ReserveStock",
+			wantStatus: shared.OrderStatusRejected,
+		},
+		{
+			name:       "leaves an order the customer cancelled",
+			status:     []shared.OrderStatus{shared.OrderStatusCancelled},
+			wantFailed: "ConfirmOrder",
+			wantStatus: shared.OrderStatusCancelled,
+		},
+		{
+			name:       "confirms an order already confirmed from the inventory outcome",
+			status:     []shared.OrderStatus{shared.OrderStatusConfirmed},
+			wantStatus: shared.OrderStatusConfirmed,
+		},
+		{
+			name:       "cancels a confirmed order whose reservation failed later",
+			status:     []shared.OrderStatus{shared.OrderStatusConfirmed},
+			failures:   map[saga.Task]error{reserve: errors.New("States.Timeout")},
+			wantFailed: "ReserveStock",
+			wantStatus: shared.OrderStatusCancelled,
+		},
+		{
+			name:       "leaves a shipped order when aborting",
+			status:     []shared.OrderStatus{shared.OrderStatusConfirmed, shared.OrderStatusProcessing, shared.OrderStatusShipped},
+			failures:   map[saga.Task]error{reserve: errors.New("States.Timeout")},
+			wantFailed: "ReserveStock",
+			wantStatus: shared.OrderStatusShipped,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			db := shared.NewMemory()
+			now := time.Now().UTC().Format(time.RFC3339)
+			order, err := db.CreateOrder(ctx, shared.Order{
+				CustomerID: "customer-1",
+				Status:     shared.OrderStatusPending,
+				Items:      []shared.OrderItem{{ID: "1", ItemID: "a", Quantity: 1, UnitPrice: 2}},
+				CreatedAt:  now,
+				UpdatedAt:  now,
+			}, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, status := range tt.status {
+				if _, err := db.UpdateOrderStatus(ctx, order.ID, status, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			handler := NewHandler(db)
+			input := saga.Input{EventID: "event-1", OrderID: order.ID}
+			result := saga.Run(ctx, saga.Fulfilment, &invoker{handler: handler, failures: tt.failures}, input)
+			if result.Failed != tt.wantFailed {
+				t.Fatalf("failed step %q, want %q (error: %v)", result.Failed, tt.wantFailed, result.Err)
+			}
+			var transitionErr *shared.InvalidTransitionError
+			if tt.wantFailed == "ConfirmOrder" && !errors.As(result.Err, &transitionErr) {
+				t.Errorf("confirm failed with %v, want an *InvalidTransitionError", result.Err)
+			}
+			checkStatus(t, db, order.ID, tt.wantStatus)
+
+			// Step Functions may run a task again, e.g. after a timeout; the
+			// last orders task repeated succeeds and leaves the order as it is.
+			for i := len(result.Invoked) - 1; i >= 0; i-- {
+				if task := result.Invoked[i]; task.Service == saga.ServiceOrders {
+					input.Action = task.Action
+					if err := handler.HandleRequest(ctx, input); err != nil {
+						t.Errorf("repeated %s failed: %v", task, err)
+					}
+					break
+				}
+			}
+			checkStatus(t, db, order.ID, tt.wantStatus)
+		})
+	}
+}
+
+func TestAbortUnknownOrder(t *testing.T) {
+	handler := NewHandler(shared.NewMemory())
+	if err := handler.HandleRequest(context.Background(), saga.Input{Action: saga.ActionAbort, OrderID: "missing"}); err != nil {
+		t.Errorf("aborting an unknown order failed: %v", err)
+	}
+}
+
+func checkStatus(t *testing.T, db shared.OrderRepository, orderID string, want shared.OrderStatus) {
+	t.Helper()
+	order, err := db.GetOrder(context.Background(), orderID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != want {
+		t.Errorf("order status %s, want %s", order.Status, want)
+	}
+}
@@ -0,0 +1,81 @@
+package shared
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	orderPrefix     = "ORDER#"
+	orderItemPrefix = "ITEM#"
+)
+
+// OrderKey returns the primary key of an order header record.
+func OrderKey(id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: orderPrefix + id},
+		"SK": &types.AttributeValueMemberS{Value: orderPrefix + id},
+	}
+}
+
+// orderItemRecord is the record of an order line, which also carries the
+// order's creation time.
+type orderItemRecord struct {
+	OrderItem
+	CreatedAt string `dynamodbav:"created_at"`
+}
+
+// MarshalOrder returns the header record of order, keys included.
+func MarshalOrder(order Order) (map[string]types.AttributeValue, error) {
+	record, err := attributevalue.MarshalMap(order)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal order %s: %v", order.ID, err)
+	}
+	for name, value := range OrderKey(order.ID) {
+		record[name] = value
+	}
+	return record, nil
+}
+
+// UnmarshalOrder reads an order header record, or a partial one such as
+// an update result or stream image. The ID comes from the partition key.
+func UnmarshalOrder(record map[string]types.AttributeValue) (Order, error) {
+	var order Order
+	if err := attributevalue.UnmarshalMap(record, &order); err != nil {
+		return Order{}, fmt.Errorf("failed to unmarshal order: %v", err)
+	}
+	if pk, ok := record["PK"].(*types.AttributeValueMemberS); ok {
+		order.ID = strings.TrimPrefix(pk.Value, orderPrefix)
+	}
+	return order, nil
+}
+
+// MarshalOrderItem returns the record of a line of order, keys included.
+func MarshalOrderItem(order Order, item OrderItem) (map[string]types.AttributeValue, error) {
+	record, err := attributevalue.MarshalMap(orderItemRecord{OrderItem: item, CreatedAt: order.CreatedAt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal line %s of order %s: %v", item.ID, order.ID, err)
+	}
+	record["PK"] = &types.AttributeValueMemberS{Value: orderPrefix + order.ID}
+	record["SK"] = &types.AttributeValueMemberS{Value: orderItemPrefix + item.ID}
+	return record, nil
+}
+
+// UnmarshalOrderItem reads an order line record. Its IDs come from the
+// keys.
+func UnmarshalOrderItem(record map[string]types.AttributeValue) (OrderItem, error) {
+	var item orderItemRecord
+	if err := attributevalue.UnmarshalMap(record, &item); err != nil {
+		return OrderItem{}, fmt.Errorf("failed to unmarshal order line: %v", err)
+	}
+	if pk, ok := record["PK"].(*types.AttributeValueMemberS); ok {
+		item.OrderID = strings.TrimPrefix(pk.Value, orderPrefix)
+	}
+	if sk, ok := record["SK"].(*types.AttributeValueMemberS); ok {
+		item.ID = strings.TrimPrefix(sk.Value, orderItemPrefix)
+	}
+	return item.OrderItem, nil
+}
@@ -0,0 +1,101 @@
+package shared
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrderRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		order Order
+	}{
+		{
+			name: "every field",
+			order: Order{
+				ID:          "order-1",
+				CustomerID:  "customer-1",
+				Status:      OrderStatusConfirmed,
+				TotalAmount: 17.5,
+				CreatedAt:   "2024-05-01T10:00:00Z",
+				UpdatedAt:   "2024-05-02T10:00:00Z",
+			},
+		},
+		{
+			name:  "empty fields",
+			order: Order{ID: "order-2", Status: OrderStatusPending},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record, err := MarshalOrder(tt.order)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := UnmarshalOrder(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.order) {
+				t.Errorf("round trip gave %+v, want %+v", got, tt.order)
+			}
+		})
+	}
+}
+
+func TestOrderLinesAreNotStoredOnTheHeader(t *testing.T) {
+	order := Order{
+		ID:    "order-1",
+		Items: []OrderItem{{ID: "line-1", OrderID: "order-1", ItemID: "widget", Quantity: 1}},
+	}
+	record, err := MarshalOrder(order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := UnmarshalOrder(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Items != nil {
+		t.Errorf("header record carried lines %+v", got.Items)
+	}
+}
+
+func TestOrderItemRoundTrip(t *testing.T) {
+	order := Order{ID: "order-1", CreatedAt: "2024-05-01T10:00:00Z"}
+	tests := []struct {
+		name string
+		item OrderItem
+	}{
+		{
+			name: "every field",
+			item: OrderItem{ID: "line-1", OrderID: "order-1", ItemID: "widget", Quantity: 3, UnitPrice: 2.5},
+		},
+		{
+			name: "unpriced line",
+			item: OrderItem{ID: "line-2", OrderID: "order-1", ItemID: "sample"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stored := tt.item
+			stored.TotalPrice = float64(stored.Quantity) * stored.UnitPrice
+			record, err := MarshalOrderItem(order, stored)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, ok := record["created_at"]; !ok {
+				t.Error("line record has no created_at")
+			}
+
+			// TotalPrice is derived, so it does not survive the round trip.
+			got, err := UnmarshalOrderItem(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.item {
+				t.Errorf("round trip gave %+v, want %+v", got, tt.item)
+			}
+		})
+	}
+}
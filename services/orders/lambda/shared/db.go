@@ -2,176 +2,525 @@ package shared
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"serp/services/shared/idempotency"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/google/uuid"
 )
 
+// MaxOrderLines keeps an order, header and idempotency record included,
+// within the 100-item limit of a single DynamoDB transaction.
+const MaxOrderLines = 98
+
+var (
+	ErrOrderExists       = errors.New("order already exists")
+	ErrTooManyOrderLines = errors.New("too many order lines")
+	ErrInvalidDateRange  = errors.New("invalid date range")
+)
+
+// DB is the OrderRepository stored in the service table, whose name is fixed
+// when the DB is created. It keeps the consumer ledger in the same table.
 type DB struct {
-	client *dynamodb.Client
+	*idempotency.TableLedger
+	client    *dynamodb.Client
+	tableName string
 }
 
-func NewDB(cfg aws.Config) *DB {
+func NewDB(client *dynamodb.Client, tableName string) *DB {
 	return &DB{
-		client: dynamodb.NewFromConfig(cfg),
+		TableLedger: idempotency.NewTableLedger(client, tableName),
+		client:      client,
+		tableName:   tableName,
 	}
 }
 
+// GetOrder reads the order's whole item collection, the header row and
+// its line rows, and returns the assembled order with computed totals.
 func (db *DB) GetOrder(ctx context.Context, id string) (*Order, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
-	}
-
-	result, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", id)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", id)},
-		},
-	})
+	rows, err := db.queryOrderRows(ctx, id, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get order: %v", err)
 	}
-	if result.Item == nil {
+
+	var order *Order
+	items := make([]OrderItem, 0, len(rows))
+	for _, row := range rows {
+		sk, _ := row["SK"].(*types.AttributeValueMemberS)
+		switch {
+		case sk == nil:
+			continue
+		case strings.HasPrefix(sk.Value, "ORDER#"):
+			header, err := UnmarshalOrder(row)
+			if err != nil {
+				return nil, err
+			}
+			order = &header
+		case strings.HasPrefix(sk.Value, "ITEM#"):
+			item, err := UnmarshalOrderItem(row)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+	}
+	if order == nil {
 		return nil, nil
 	}
 
-	order := UnmarshalOrder(result.Item)
-	return &order, nil
+	order.Items = items
+	order.ComputeTotals()
+	return order, nil
 }
 
-func (db *DB) ListOrders(ctx context.Context) ([]Order, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+// queryOrderRows returns every row in an order's item collection whose sort
+// key starts with skPrefix, following pagination. Reads are strongly
+// consistent, so a collection read right after it is written is complete.
+func (db *DB) queryOrderRows(ctx context.Context, orderID, skPrefix string) ([]map[string]types.AttributeValue, error) {
+	keyCondition := "PK = :pk"
+	values := map[string]types.AttributeValue{
+		":pk": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", orderID)},
+	}
+	if skPrefix != "" {
+		keyCondition += " AND begins_with(SK, :prefix)"
+		values[":prefix"] = &types.AttributeValueMemberS{Value: skPrefix}
 	}
 
-	result, err := db.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName:        aws.String(tableName),
-		FilterExpression: aws.String("begins_with(PK, :prefix)"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":prefix": &types.AttributeValueMemberS{Value: "ORDER#"},
-		},
-	})
+	var rows []map[string]types.AttributeValue
+	var startKey map[string]types.AttributeValue
+	for {
+		result, err := db.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(db.tableName),
+			KeyConditionExpression:    aws.String(keyCondition),
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
+			ConsistentRead:            aws.Bool(true),
+		})
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, result.Items...)
+		if len(result.LastEvaluatedKey) == 0 {
+			return rows, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+const (
+	CustomerIndex = "CustomerIndex"
+	StatusIndex   = "StatusIndex"
+)
+
+// OrderPage is one page of ListOrders results. LastKey is nil on the last
+// page.
+type OrderPage struct {
+	Orders  []Order
+	LastKey map[string]types.AttributeValue
+}
+
+// OrdersScope identifies the query ListOrders runs for filter: the index it
+// reads and the filter values. A cursor can only resume the query it was
+// issued for.
+func OrdersScope(filter OrderFilterInput) string {
+	return fmt.Sprintf("%s %q %q %q %q", ordersIndex(filter), filter.CustomerID, filter.Status, filter.StartDate, filter.EndDate)
+}
+
+// ordersIndex returns the index ListOrders reads for filter.
+func ordersIndex(filter OrderFilterInput) string {
+	switch {
+	case filter.CustomerID != "":
+		return CustomerIndex
+	case filter.Status != "":
+		return StatusIndex
+	default:
+		return "table"
+	}
+}
+
+// ListOrders reads one page of order headers matching filter. A customer
+// filter is served by CustomerIndex and a status-only filter by StatusIndex,
+// both read newest first on created_at so the date range narrows the key
+// condition. Without either the base table is scanned page by page, which
+// returns the orders unordered.
+func (db *DB) ListOrders(ctx context.Context, filter OrderFilterInput, limit int32, startKey map[string]types.AttributeValue) (*OrderPage, error) {
+	dateRange, err := createdAtRange(filter.StartDate, filter.EndDate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan orders: %v", err)
+		return nil, err
 	}
 
-	orders := make([]Order, 0, len(result.Items))
-	for _, item := range result.Items {
-		order := UnmarshalOrder(item)
+	names := map[string]string{}
+	values := map[string]types.AttributeValue{}
+	var keyConditions []string
+	var conditions []string
+
+	switch ordersIndex(filter) {
+	case CustomerIndex:
+		names["#customer_id"] = "customer_id"
+		values[":customer_id"] = &types.AttributeValueMemberS{Value: filter.CustomerID}
+		keyConditions = append(keyConditions, "#customer_id = :customer_id")
+		if filter.Status != "" {
+			names["#status"] = "status"
+			values[":status"] = &types.AttributeValueMemberS{Value: string(filter.Status)}
+			conditions = append(conditions, "#status = :status")
+		}
+	case StatusIndex:
+		names["#status"] = "status"
+		values[":status"] = &types.AttributeValueMemberS{Value: string(filter.Status)}
+		keyConditions = append(keyConditions, "#status = :status")
+	default:
+		names["#pk"] = "PK"
+		names["#sk"] = "SK"
+		values[":prefix"] = &types.AttributeValueMemberS{Value: "ORDER#"}
+		conditions = append(conditions, "begins_with(#pk, :prefix)", "#pk = #sk")
+	}
+
+	if dateRange != "" {
+		names["#created_at"] = "created_at"
+		if filter.StartDate != "" {
+			values[":start_date"] = &types.AttributeValueMemberS{Value: normalizeDate(filter.StartDate)}
+		}
+		if filter.EndDate != "" {
+			values[":end_date"] = &types.AttributeValueMemberS{Value: normalizeDate(filter.EndDate)}
+		}
+		if len(keyConditions) > 0 {
+			keyConditions = append(keyConditions, dateRange)
+		} else {
+			conditions = append(conditions, dateRange)
+		}
+	}
+
+	var filterExpression *string
+	if len(conditions) > 0 {
+		filterExpression = aws.String(strings.Join(conditions, " AND "))
+	}
+
+	var rows []map[string]types.AttributeValue
+	var lastKey map[string]types.AttributeValue
+	if len(keyConditions) == 0 {
+		result, err := db.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(db.tableName),
+			FilterExpression:          filterExpression,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			Limit:                     aws.Int32(limit),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan orders: %v", err)
+		}
+		rows, lastKey = result.Items, result.LastEvaluatedKey
+	} else {
+		result, err := db.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(db.tableName),
+			IndexName:                 aws.String(ordersIndex(filter)),
+			KeyConditionExpression:    aws.String(strings.Join(keyConditions, " AND ")),
+			FilterExpression:          filterExpression,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ScanIndexForward:          aws.Bool(false),
+			Limit:                     aws.Int32(limit),
+			ExclusiveStartKey:         startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to query orders: %v", err)
+		}
+		rows, lastKey = result.Items, result.LastEvaluatedKey
+	}
+
+	orders := make([]Order, 0, len(rows))
+	for _, row := range rows {
+		order, err := UnmarshalOrder(row)
+		if err != nil {
+			return nil, err
+		}
 		orders = append(orders, order)
 	}
+	if err := db.loadOrderItems(ctx, orders); err != nil {
+		return nil, err
+	}
 
-	return orders, nil
+	return &OrderPage{Orders: orders, LastKey: lastKey}, nil
 }
 
-func (db *DB) CreateOrder(ctx context.Context, order Order) (*Order, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+// loadOrderItems fills in the lines and totals of a page of order headers,
+// querying the orders' item collections concurrently.
+func (db *DB) loadOrderItems(ctx context.Context, orders []Order) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(orders))
+	for i := range orders {
+		wg.Add(1)
+		go func(order *Order, errp *error) {
+			defer wg.Done()
+			rows, err := db.queryOrderRows(ctx, order.ID, "ITEM#")
+			if err != nil {
+				*errp = fmt.Errorf("failed to query items of order %s: %v", order.ID, err)
+				return
+			}
+			order.Items = make([]OrderItem, 0, len(rows))
+			for _, row := range rows {
+				item, err := UnmarshalOrderItem(row)
+				if err != nil {
+					*errp = err
+					return
+				}
+				order.Items = append(order.Items, item)
+			}
+			order.ComputeTotals()
+		}(&orders[i], &errs[i])
 	}
+	wg.Wait()
 
-	if order.ID == "" {
-		order.ID = uuid.New().String()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	_, err := db.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(tableName),
-		Item: map[string]types.AttributeValue{
-			"PK":           &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", order.ID)},
-			"SK":           &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", order.ID)},
-			"customer_id":  &types.AttributeValueMemberS{Value: order.CustomerID},
-			"status":       &types.AttributeValueMemberS{Value: string(order.Status)},
-			"total_amount": &types.AttributeValueMemberN{Value: strconv.FormatFloat(order.TotalAmount, 'f', 2, 64)},
-			"created_at":   &types.AttributeValueMemberS{Value: order.CreatedAt},
-			"updated_at":   &types.AttributeValueMemberS{Value: order.UpdatedAt},
-		},
-	})
+// createdAtRange returns the created_at condition for an optional date
+// range. It fails with ErrInvalidDateRange unless both bounds are
+// AWSDateTime values in order.
+func createdAtRange(startDate, endDate string) (string, error) {
+	for _, date := range []string{startDate, endDate} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, date); err != nil {
+			return "", fmt.Errorf("%w: %q is not an AWSDateTime", ErrInvalidDateRange, date)
+		}
+	}
+
+	switch {
+	case startDate != "" && endDate != "":
+		if normalizeDate(startDate) > normalizeDate(endDate) {
+			return "", fmt.Errorf("%w: startDate %s is after endDate %s", ErrInvalidDateRange, startDate, endDate)
+		}
+		return "#created_at BETWEEN :start_date AND :end_date", nil
+	case startDate != "":
+		return "#created_at >= :start_date", nil
+	case endDate != "":
+		return "#created_at <= :end_date", nil
+	default:
+		return "", nil
+	}
+}
+
+// normalizeDate converts an AWSDateTime to the UTC RFC 3339 form created_at
+// is stored in, so the two compare correctly as strings.
+func normalizeDate(date string) string {
+	t, err := time.Parse(time.RFC3339, date)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create order: %v", err)
+		return date
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func (db *DB) ListOrderItems(ctx context.Context, orderID string) ([]OrderItem, error) {
+	rows, err := db.queryOrderRows(ctx, orderID, "ITEM#")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order items: %v", err)
 	}
 
-	for _, item := range order.Items {
-		_, err := db.client.PutItem(ctx, &dynamodb.PutItemInput{
-			TableName: aws.String(tableName),
-			Item: map[string]types.AttributeValue{
-				"PK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", order.ID)},
-				"SK":         &types.AttributeValueMemberS{Value: fmt.Sprintf("ITEM#%s", item.ID)},
-				"item_id":    &types.AttributeValueMemberS{Value: item.ID},
-				"quantity":   &types.AttributeValueMemberN{Value: strconv.Itoa(item.Quantity)},
-				"unit_price": &types.AttributeValueMemberN{Value: strconv.FormatFloat(item.UnitPrice, 'f', 2, 64)},
-				"created_at": &types.AttributeValueMemberS{Value: order.CreatedAt},
-			},
-		})
+	items := make([]OrderItem, 0, len(rows))
+	for _, row := range rows {
+		item, err := UnmarshalOrderItem(row)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create order item: %v", err)
+			return nil, err
 		}
+		items = append(items, item)
 	}
 
-	return &order, nil
+	return items, nil
 }
 
-func (db *DB) UpdateOrderStatus(ctx context.Context, id string, status OrderStatus) (*Order, error) {
-	tableName := os.Getenv("TABLE_NAME")
-	if tableName == "" {
-		return nil, fmt.Errorf("TABLE_NAME environment variable is not set")
+// CreateOrder writes the order header and all of its lines in a single
+// transaction, so an order is either stored completely or not at all. The
+// header put is conditioned on the order ID being unused. With an
+// idempotency key the transaction also records the key, and a replay of the
+// request returns the order the first request created.
+func (db *DB) CreateOrder(ctx context.Context, order Order, key *idempotency.Key) (*Order, error) {
+	if len(order.Items) > MaxOrderLines {
+		return nil, fmt.Errorf("%w: %d lines, at most %d allowed", ErrTooManyOrderLines, len(order.Items), MaxOrderLines)
 	}
+	if order.ID == "" {
+		order.ID = uuid.New().String()
+	}
+	order.ComputeTotals()
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	result, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
-		TableName: aws.String(tableName),
-		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", id)},
-			"SK": &types.AttributeValueMemberS{Value: fmt.Sprintf("ORDER#%s", id)},
-		},
-		UpdateExpression: aws.String("SET #status = :status, #updated_at = :updated_at"),
-		ExpressionAttributeNames: map[string]string{
-			"#status":     "status",
-			"#updated_at": "updated_at",
-		},
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":status":     &types.AttributeValueMemberS{Value: string(status)},
-			":updated_at": &types.AttributeValueMemberS{Value: now},
+	header, err := MarshalOrder(order)
+	if err != nil {
+		return nil, err
+	}
+	writes := make([]types.TransactWriteItem, 0, len(order.Items)+2)
+	headerIndex := 0
+	if key != nil {
+		writes = append(writes, key.Put(db.tableName, order.ID, time.Now()))
+		headerIndex = 1
+	}
+	writes = append(writes, types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(db.tableName),
+			Item:                header,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
 		},
-		ReturnValues: types.ReturnValueAllNew,
+	})
+	for _, item := range order.Items {
+		record, err := MarshalOrderItem(order, item)
+		if err != nil {
+			return nil, err
+		}
+		writes = append(writes, types.TransactWriteItem{
+			Put: &types.Put{
+				TableName: aws.String(db.tableName),
+				Item:      record,
+			},
+		})
+	}
+
+	_, err = db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: writes,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to update order status: %v", err)
+		if key != nil && idempotency.Replayed(err, 0) {
+			orderID, err := idempotency.Lookup(ctx, db.client, db.tableName, *key)
+			if err != nil {
+				return nil, err
+			}
+			return db.GetOrder(ctx, orderID)
+		}
+		var cancelled *types.TransactionCanceledException
+		if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > headerIndex &&
+			aws.ToString(cancelled.CancellationReasons[headerIndex].Code) == "ConditionalCheckFailed" {
+			return nil, fmt.Errorf("%w: %s", ErrOrderExists, order.ID)
+		}
+		return nil, fmt.Errorf("failed to create order: %v", err)
 	}
 
-	order := UnmarshalOrder(result.Attributes)
 	return &order, nil
 }
 
-func UnmarshalOrder(av map[string]types.AttributeValue) Order {
-	order := Order{}
-	if v, ok := av["ID"].(*types.AttributeValueMemberS); ok {
-		order.ID = v.Value
+// UpdateOrderStatus moves an order to status if the transition graph allows
+// it from the order's current status. The check is a condition on the
+// stored status, so concurrent updates cannot race an order past a state.
+// A non-nil processed entry is written in the same transaction, and
+// idempotency.ErrAlreadyProcessed is returned if its event was already
+// handled.
+func (db *DB) UpdateOrderStatus(ctx context.Context, id string, status OrderStatus, processed *idempotency.Processed) (*Order, error) {
+	if !status.Valid() {
+		return nil, &InvalidStatusError{Status: status}
 	}
-	if v, ok := av["customer_id"].(*types.AttributeValueMemberS); ok {
-		order.CustomerID = v.Value
+
+	key := OrderKey(id)
+
+	from := statusesBefore(status)
+	if len(from) == 0 {
+		// Nothing moves into status, so the update can only fail; read the
+		// current status to report the rejected transition.
+		result, err := db.client.GetItem(ctx, &dynamodb.GetItemInput{
+			TableName: aws.String(db.tableName),
+			Key:       key,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get order: %v", err)
+		}
+		return nil, transitionError(id, result.Item, status)
+	}
+
+	placeholders := make([]string, len(from))
+	values := map[string]types.AttributeValue{
+		":status":     &types.AttributeValueMemberS{Value: string(status)},
+		":updated_at": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+	}
+	for i, current := range from {
+		placeholders[i] = fmt.Sprintf(":from%d", i)
+		values[placeholders[i]] = &types.AttributeValueMemberS{Value: string(current)}
+	}
+
+	updateExpression := aws.String("SET #status = :status, #updated_at = :updated_at")
+	conditionExpression := aws.String(fmt.Sprintf("#status IN (%s)", strings.Join(placeholders, ", ")))
+	names := map[string]string{
+		"#status":     "status",
+		"#updated_at": "updated_at",
 	}
-	if v, ok := av["status"].(*types.AttributeValueMemberS); ok {
-		order.Status = OrderStatus(v.Value)
+
+	if processed != nil {
+		_, err := db.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+			TransactItems: []types.TransactWriteItem{
+				processed.Put(db.tableName, time.Now()),
+				{
+					Update: &types.Update{
+						TableName:                           aws.String(db.tableName),
+						Key:                                 key,
+						UpdateExpression:                    updateExpression,
+						ConditionExpression:                 conditionExpression,
+						ExpressionAttributeNames:            names,
+						ExpressionAttributeValues:           values,
+						ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+					},
+				},
+			},
+		})
+		if err != nil {
+			if idempotency.Duplicate(err) {
+				return nil, idempotency.ErrAlreadyProcessed
+			}
+			var cancelled *types.TransactionCanceledException
+			if errors.As(err, &cancelled) && len(cancelled.CancellationReasons) > 1 &&
+				aws.ToString(cancelled.CancellationReasons[1].Code) == "ConditionalCheckFailed" {
+				return nil, transitionError(id, cancelled.CancellationReasons[1].Item, status)
+			}
+			return nil, fmt.Errorf("failed to update order status: %v", err)
+		}
+		return db.GetOrder(ctx, id)
 	}
-	if v, ok := av["total_amount"].(*types.AttributeValueMemberN); ok {
-		if f, err := strconv.ParseFloat(v.Value, 64); err == nil {
-			order.TotalAmount = f
+
+	result, err := db.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                           aws.String(db.tableName),
+		Key:                                 key,
+		UpdateExpression:                    updateExpression,
+		ConditionExpression:                 conditionExpression,
+		ExpressionAttributeNames:            names,
+		ExpressionAttributeValues:           values,
+		ReturnValues:                        types.ReturnValueAllNew,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil, transitionError(id, conditionErr.Item, status)
 		}
+		return nil, fmt.Errorf("failed to update order status: %v", err)
 	}
-	if v, ok := av["created_at"].(*types.AttributeValueMemberS); ok {
-		order.CreatedAt = v.Value
+
+	order, err := UnmarshalOrder(result.Attributes)
+	if err != nil {
+		return nil, err
 	}
-	if v, ok := av["updated_at"].(*types.AttributeValueMemberS); ok {
-		order.UpdatedAt = v.Value
+	order.ID = id
+	orders := []Order{order}
+	if err := db.loadOrderItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
+
+// transitionError explains why an order whose header is current could not
+// move to status.
+func transitionError(id string, current map[string]types.AttributeValue, status OrderStatus) error {
+	if current == nil {
+		return &OrderNotFoundError{OrderID: id}
 	}
-	return order
+	// An undecodable header still rejected the transition; report it with
+	// an unknown current status rather than hide the rejection.
+	order, _ := UnmarshalOrder(current)
+	return &InvalidTransitionError{OrderID: id, From: order.Status, To: status}
 }
@@ -0,0 +1,28 @@
+package shared_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/orders/lambda/shared/repotest"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// TestDB runs the checks against the table named by ORDERS_TEST_TABLE. The
+// checks leave their records behind, so point it at a throwaway table with
+// the keys and indexes of the orders table.
+func TestDB(t *testing.T) {
+	table := os.Getenv("ORDERS_TEST_TABLE")
+	if table == "" {
+		t.Skip("ORDERS_TEST_TABLE is not set")
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background())
+	if err != nil {
+		t.Fatalf("failed to load AWS config: %v", err)
+	}
+	repotest.Run(t, shared.NewDB(dynamodb.NewFromConfig(cfg), table))
+}
@@ -0,0 +1,226 @@
+package shared
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"serp/services/shared/idempotency"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/uuid"
+)
+
+// Memory is the OrderRepository kept in process. It follows DB's
+// conditional writes: an order is created with all of its lines or not at
+// all, its ID is never reused, and its status only moves along the
+// transition graph.
+type Memory struct {
+	mu     sync.Mutex
+	ledger *idempotency.Memory
+	orders map[string]Order
+	lines  map[string][]OrderItem
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		ledger: idempotency.NewMemory(),
+		orders: make(map[string]Order),
+		lines:  make(map[string][]OrderItem),
+	}
+}
+
+func (m *Memory) Record(ctx context.Context, processed idempotency.Processed) error {
+	return m.ledger.Record(ctx, processed)
+}
+
+func (m *Memory) Outcome(ctx context.Context, processed idempotency.Processed) ([]byte, error) {
+	return m.ledger.Outcome(ctx, processed)
+}
+
+func (m *Memory) GetOrder(ctx context.Context, id string) (*Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.order(id), nil
+}
+
+// order assembles an order from its header and lines, or returns nil.
+func (m *Memory) order(id string) *Order {
+	order, ok := m.orders[id]
+	if !ok {
+		return nil
+	}
+	order.Items = m.orderItems(id)
+	order.ComputeTotals()
+	return &order
+}
+
+func (m *Memory) orderItems(orderID string) []OrderItem {
+	return append(make([]OrderItem, 0, len(m.lines[orderID])), m.lines[orderID]...)
+}
+
+// ListOrders pages through the orders newest first, the order DB reads
+// them in from an index. Without a customer or status filter DB scans the
+// table instead, so callers must not rely on that order. Like DB it
+// evaluates limit orders per page before filtering them, and the date range
+// only narrows the candidates when an index is read.
+func (m *Memory) ListOrders(ctx context.Context, filter OrderFilterInput, limit int32, startKey map[string]types.AttributeValue) (*OrderPage, error) {
+	if _, err := createdAtRange(filter.StartDate, filter.EndDate); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index := ordersIndex(filter)
+	var candidates []Order
+	for _, order := range m.orders {
+		switch index {
+		case CustomerIndex:
+			if order.CustomerID != filter.CustomerID || !createdWithin(filter, order) {
+				continue
+			}
+		case StatusIndex:
+			if order.Status != filter.Status || !createdWithin(filter, order) {
+				continue
+			}
+		}
+		candidates = append(candidates, order)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return orderBefore(candidates[i], candidates[j])
+	})
+
+	if startKey != nil {
+		last := Order{ID: strings.TrimPrefix(keyString(startKey, "PK"), orderPrefix), CreatedAt: keyString(startKey, "created_at")}
+		candidates = candidates[sort.Search(len(candidates), func(i int) bool {
+			return orderBefore(last, candidates[i])
+		}):]
+	}
+
+	page := &OrderPage{Orders: []Order{}}
+	if limit > 0 && len(candidates) > int(limit) {
+		candidates = candidates[:limit]
+		last := candidates[len(candidates)-1]
+		page.LastKey = OrderKey(last.ID)
+		page.LastKey["created_at"] = &types.AttributeValueMemberS{Value: last.CreatedAt}
+	}
+	for _, order := range candidates {
+		if filter.Status != "" && order.Status != filter.Status {
+			continue
+		}
+		if !createdWithin(filter, order) {
+			continue
+		}
+		page.Orders = append(page.Orders, *m.order(order.ID))
+	}
+	return page, nil
+}
+
+func orderBefore(a, b Order) bool {
+	if a.CreatedAt != b.CreatedAt {
+		return a.CreatedAt > b.CreatedAt
+	}
+	return a.ID > b.ID
+}
+
+func createdWithin(filter OrderFilterInput, order Order) bool {
+	if filter.StartDate != "" && order.CreatedAt < normalizeDate(filter.StartDate) {
+		return false
+	}
+	if filter.EndDate != "" && order.CreatedAt > normalizeDate(filter.EndDate) {
+		return false
+	}
+	return true
+}
+
+func keyString(key map[string]types.AttributeValue, name string) string {
+	if value, ok := key[name].(*types.AttributeValueMemberS); ok {
+		return value.Value
+	}
+	return ""
+}
+
+func (m *Memory) ListOrderItems(ctx context.Context, orderID string) ([]OrderItem, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.orderItems(orderID), nil
+}
+
+func (m *Memory) CreateOrder(ctx context.Context, order Order, key *idempotency.Key) (*Order, error) {
+	if len(order.Items) > MaxOrderLines {
+		return nil, fmt.Errorf("%w: %d lines, at most %d allowed", ErrTooManyOrderLines, len(order.Items), MaxOrderLines)
+	}
+	if order.ID == "" {
+		order.ID = uuid.New().String()
+	}
+	order.ComputeTotals()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orderID, err := m.ledger.Claim(key, order.ID, func() error {
+		if _, ok := m.orders[order.ID]; ok {
+			return fmt.Errorf("%w: %s", ErrOrderExists, order.ID)
+		}
+		lines := make([]OrderItem, len(order.Items))
+		for i, item := range order.Items {
+			item.OrderID = order.ID
+			lines[i] = item
+		}
+		sort.Slice(lines, func(i, j int) bool { return lines[i].ID < lines[j].ID })
+
+		header := order
+		header.Items = nil
+		m.orders[order.ID] = header
+		m.lines[order.ID] = lines
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if orderID != order.ID {
+		return m.order(orderID), nil
+	}
+	return &order, nil
+}
+
+func (m *Memory) UpdateOrderStatus(ctx context.Context, id string, status OrderStatus, processed *idempotency.Processed) (*Order, error) {
+	if !status.Valid() {
+		return nil, &InvalidStatusError{Status: status}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(statusesBefore(status)) == 0 {
+		// DB rejects these without writing, so the ledger is not checked.
+		return nil, m.transitionError(id, status)
+	}
+
+	err := m.ledger.Apply(processed, func() error {
+		order, ok := m.orders[id]
+		if !ok || !order.Status.CanTransitionTo(status) {
+			return m.transitionError(id, status)
+		}
+		order.Status = status
+		order.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+		m.orders[id] = order
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m.order(id), nil
+}
+
+func (m *Memory) transitionError(id string, status OrderStatus) error {
+	order, ok := m.orders[id]
+	if !ok {
+		return &OrderNotFoundError{OrderID: id}
+	}
+	return &InvalidTransitionError{OrderID: id, From: order.Status, To: status}
+}
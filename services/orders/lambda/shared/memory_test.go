@@ -0,0 +1,12 @@
+package shared_test
+
+import (
+	"testing"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/orders/lambda/shared/repotest"
+)
+
+func TestMemory(t *testing.T) {
+	repotest.Run(t, shared.NewMemory())
+}
@@ -0,0 +1,139 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+var ErrItemNotPriced = errors.New("item has no price")
+
+// PriceList prices the lines of new orders.
+type PriceList interface {
+	// Prices returns the unit price of each of itemIDs that has one.
+	Prices(ctx context.Context, itemIDs []string) (map[string]float64, error)
+}
+
+var (
+	_ PriceList = (*InventoryPrices)(nil)
+	_ PriceList = StaticPrices(nil)
+)
+
+// PriceOrder sets the unit price of every line of order. It fails with
+// ErrItemNotPriced, naming the items, if any line has no price.
+func PriceOrder(ctx context.Context, prices PriceList, order *Order) error {
+	itemIDs := make([]string, len(order.Items))
+	for i, item := range order.Items {
+		itemIDs[i] = item.ItemID
+	}
+	unitPrices, err := prices.Prices(ctx, itemIDs)
+	if err != nil {
+		return err
+	}
+
+	var unpriced []string
+	for i, item := range order.Items {
+		price, ok := unitPrices[item.ItemID]
+		if !ok {
+			unpriced = append(unpriced, item.ItemID)
+			continue
+		}
+		order.Items[i].UnitPrice = price
+	}
+	if len(unpriced) > 0 {
+		sort.Strings(unpriced)
+		return fmt.Errorf("%w: %s", ErrItemNotPriced, strings.Join(unpriced, ", "))
+	}
+	return nil
+}
+
+// InventoryPrices reads unit prices from the inventory table, where an item
+// is the record ITEM#<id> with its price in unit_price.
+type InventoryPrices struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewInventoryPrices(client *dynamodb.Client, tableName string) *InventoryPrices {
+	return &InventoryPrices{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// maxBatchGetKeys is the most keys one BatchGetItem request reads.
+const maxBatchGetKeys = 100
+
+func (p *InventoryPrices) Prices(ctx context.Context, itemIDs []string) (map[string]float64, error) {
+	var keys []map[string]types.AttributeValue
+	seen := make(map[string]bool)
+	for _, itemID := range itemIDs {
+		if !seen[itemID] {
+			seen[itemID] = true
+			keys = append(keys, inventoryItemKey(itemID))
+		}
+	}
+
+	prices := make(map[string]float64, len(keys))
+	for len(keys) > 0 {
+		batch := keys[:min(len(keys), maxBatchGetKeys)]
+		keys = keys[len(batch):]
+		result, err := p.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{
+			RequestItems: map[string]types.KeysAndAttributes{
+				p.tableName: {
+					Keys:                 batch,
+					ProjectionExpression: aws.String("PK, unit_price"),
+				},
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read item prices: %v", err)
+		}
+		for _, row := range result.Responses[p.tableName] {
+			var record struct {
+				PK        string   `dynamodbav:"PK"`
+				UnitPrice *float64 `dynamodbav:"unit_price"`
+			}
+			if err := attributevalue.UnmarshalMap(row, &record); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal item price: %v", err)
+			}
+			if record.UnitPrice != nil {
+				prices[strings.TrimPrefix(record.PK, inventoryItemPrefix)] = *record.UnitPrice
+			}
+		}
+		if unprocessed, ok := result.UnprocessedKeys[p.tableName]; ok {
+			keys = append(keys, unprocessed.Keys...)
+		}
+	}
+	return prices, nil
+}
+
+const inventoryItemPrefix = "ITEM#"
+
+func inventoryItemKey(itemID string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: inventoryItemPrefix + itemID},
+		"SK": &types.AttributeValueMemberS{Value: inventoryItemPrefix + itemID},
+	}
+}
+
+// StaticPrices is a PriceList of fixed prices by item ID, for running
+// handlers without the inventory table.
+type StaticPrices map[string]float64
+
+func (p StaticPrices) Prices(ctx context.Context, itemIDs []string) (map[string]float64, error) {
+	prices := make(map[string]float64, len(itemIDs))
+	for _, itemID := range itemIDs {
+		if price, ok := p[itemID]; ok {
+			prices[itemID] = price
+		}
+	}
+	return prices, nil
+}
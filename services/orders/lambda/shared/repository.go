@@ -0,0 +1,32 @@
+package shared
+
+import (
+	"context"
+
+	"serp/services/shared/idempotency"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// OrderRepository stores orders and their lines, together with the ledger
+// of the consumers changing them. DB stores them in DynamoDB and Memory in
+// process; both pass the checks in repotest.
+type OrderRepository interface {
+	idempotency.Ledger
+
+	// GetOrder returns nil if the order does not exist.
+	GetOrder(ctx context.Context, id string) (*Order, error)
+	// ListOrders reads one page of orders matching filter, starting after
+	// startKey, the LastKey of the previous page. Orders come newest first
+	// when filter has a customer or status, and in no particular order
+	// otherwise.
+	ListOrders(ctx context.Context, filter OrderFilterInput, limit int32, startKey map[string]types.AttributeValue) (*OrderPage, error)
+	ListOrderItems(ctx context.Context, orderID string) ([]OrderItem, error)
+	CreateOrder(ctx context.Context, order Order, key *idempotency.Key) (*Order, error)
+	UpdateOrderStatus(ctx context.Context, id string, status OrderStatus, processed *idempotency.Processed) (*Order, error)
+}
+
+var (
+	_ OrderRepository = (*DB)(nil)
+	_ OrderRepository = (*Memory)(nil)
+)
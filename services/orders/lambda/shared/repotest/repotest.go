@@ -0,0 +1,366 @@
+// Package repotest holds the behaviour every OrderRepository must have.
+// The checks create their own orders under fresh IDs and customers, so
+// they can run against a table that is in use.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"serp/services/orders/lambda/shared"
+	"serp/services/shared/idempotency"
+
+	"github.com/google/uuid"
+)
+
+// Run checks that repo has the behaviour of every OrderRepository, one subtest
+// per check.
+func Run(t *testing.T, repo shared.OrderRepository) {
+	ctx := context.Background()
+	for _, check := range checks {
+		check := check
+		t.Run(check.name, func(t *testing.T) {
+			if err := check.run(ctx, repo); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// check is one behaviour of a repository. run returns why the repository
+// does not have it.
+type check struct {
+	name string
+	run  func(ctx context.Context, repo shared.OrderRepository) error
+}
+
+var checks = []check{
+	{"creates and reads back an order with its lines", createAndGet},
+	{"rejects a reused order ID and too many lines", rejectCreate},
+	{"replays a create with the same idempotency key", replayCreate},
+	{"moves an order along the status graph only", transitions},
+	{"adds a ledger entry only with its change", ledger},
+	{"pages through a customer's orders newest first", listCustomer},
+}
+
+func newOrder(customerID string, createdAt time.Time) shared.Order {
+	timestamp := createdAt.UTC().Format(time.RFC3339)
+	return shared.Order{
+		ID:         uuid.New().String(),
+		CustomerID: customerID,
+		Status:     shared.OrderStatusPending,
+		Items: []shared.OrderItem{
+			{ID: "1", ItemID: uuid.New().String(), Quantity: 2, UnitPrice: 1.25},
+			{ID: "2", ItemID: uuid.New().String(), Quantity: 1, UnitPrice: 10},
+		},
+		CreatedAt: timestamp,
+		UpdatedAt: timestamp,
+	}
+}
+
+func create(ctx context.Context, repo shared.OrderRepository, orders ...shared.Order) error {
+	for _, order := range orders {
+		if _, err := repo.CreateOrder(ctx, order, nil); err != nil {
+			return fmt.Errorf("create %s: %v", order.ID, err)
+		}
+	}
+	return nil
+}
+
+// get reads an order that must exist.
+func get(ctx context.Context, repo shared.OrderRepository, id string) (shared.Order, error) {
+	order, err := repo.GetOrder(ctx, id)
+	if err != nil {
+		return shared.Order{}, fmt.Errorf("get %s: %v", id, err)
+	}
+	if order == nil {
+		return shared.Order{}, fmt.Errorf("order %s not found", id)
+	}
+	return *order, nil
+}
+
+// sameOrder compares orders field by field, lines in ID order.
+func sameOrder(got, want shared.Order) error {
+	for _, order := range []*shared.Order{&got, &want} {
+		order.Items = append([]shared.OrderItem(nil), order.Items...)
+		sort.Slice(order.Items, func(i, j int) bool { return order.Items[i].ID < order.Items[j].ID })
+		for i := range order.Items {
+			order.Items[i].OrderID = order.ID
+		}
+	}
+	if fmt.Sprintf("%+v", got) != fmt.Sprintf("%+v", want) {
+		return fmt.Errorf("got %+v, want %+v", got, want)
+	}
+	return nil
+}
+
+func createAndGet(ctx context.Context, repo shared.OrderRepository) error {
+	order := newOrder(uuid.New().String(), time.Now())
+	created, err := repo.CreateOrder(ctx, order, nil)
+	if err != nil {
+		return err
+	}
+	if created.TotalAmount != 12.5 || created.Items[0].TotalPrice != 2.5 {
+		return fmt.Errorf("created order totals %v and line totals %+v, want 12.5", created.TotalAmount, created.Items)
+	}
+
+	got, err := get(ctx, repo, order.ID)
+	if err != nil {
+		return err
+	}
+	if err := sameOrder(got, *created); err != nil {
+		return fmt.Errorf("read back: %v", err)
+	}
+
+	lines, err := repo.ListOrderItems(ctx, order.ID)
+	if err != nil {
+		return err
+	}
+	if len(lines) != len(order.Items) {
+		return fmt.Errorf("listed %d lines, want %d", len(lines), len(order.Items))
+	}
+	for _, line := range lines {
+		if line.OrderID != order.ID {
+			return fmt.Errorf("line %s has order ID %q, want %s", line.ID, line.OrderID, order.ID)
+		}
+	}
+
+	missing, err := repo.GetOrder(ctx, uuid.New().String())
+	if err != nil {
+		return err
+	}
+	if missing != nil {
+		return fmt.Errorf("got %+v for an unknown ID, want nil", *missing)
+	}
+	return nil
+}
+
+func rejectCreate(ctx context.Context, repo shared.OrderRepository) error {
+	order := newOrder(uuid.New().String(), time.Now())
+	if err := create(ctx, repo, order); err != nil {
+		return err
+	}
+
+	reused := newOrder(uuid.New().String(), time.Now())
+	reused.ID = order.ID
+	if _, err := repo.CreateOrder(ctx, reused, nil); !errors.Is(err, shared.ErrOrderExists) {
+		return fmt.Errorf("reusing an order ID returned %v, want %v", err, shared.ErrOrderExists)
+	}
+	got, err := get(ctx, repo, order.ID)
+	if err != nil {
+		return err
+	}
+	if got.CustomerID != order.CustomerID {
+		return fmt.Errorf("rejected create changed the customer to %s", got.CustomerID)
+	}
+
+	large := newOrder(uuid.New().String(), time.Now())
+	large.Items = make([]shared.OrderItem, shared.MaxOrderLines+1)
+	for i := range large.Items {
+		large.Items[i] = shared.OrderItem{ID: fmt.Sprint(i), ItemID: uuid.New().String(), Quantity: 1}
+	}
+	if _, err := repo.CreateOrder(ctx, large, nil); !errors.Is(err, shared.ErrTooManyOrderLines) {
+		return fmt.Errorf("creating %d lines returned %v, want %v", len(large.Items), err, shared.ErrTooManyOrderLines)
+	}
+	return nil
+}
+
+func replayCreate(ctx context.Context, repo shared.OrderRepository) error {
+	value := uuid.New().String()
+	key, err := idempotency.NewKey("createOrder", value, "first")
+	if err != nil {
+		return err
+	}
+	first := newOrder(uuid.New().String(), time.Now())
+	if _, err := repo.CreateOrder(ctx, first, key); err != nil {
+		return err
+	}
+
+	replay := newOrder(first.CustomerID, time.Now())
+	created, err := repo.CreateOrder(ctx, replay, key)
+	if err != nil {
+		return fmt.Errorf("replay: %v", err)
+	}
+	if created == nil || created.ID != first.ID {
+		return fmt.Errorf("replay returned %+v, want order %s", created, first.ID)
+	}
+	if order, err := repo.GetOrder(ctx, replay.ID); err != nil || order != nil {
+		return fmt.Errorf("replay created order %s (%v)", replay.ID, err)
+	}
+
+	reused, err := idempotency.NewKey("createOrder", value, "second")
+	if err != nil {
+		return err
+	}
+	if _, err := repo.CreateOrder(ctx, newOrder(first.CustomerID, time.Now()), reused); !errors.Is(err, idempotency.ErrKeyReused) {
+		return fmt.Errorf("reusing the key for other arguments returned %v, want %v", err, idempotency.ErrKeyReused)
+	}
+	return nil
+}
+
+func transitions(ctx context.Context, repo shared.OrderRepository) error {
+	order := newOrder(uuid.New().String(), time.Now())
+	if err := create(ctx, repo, order); err != nil {
+		return err
+	}
+
+	updated, err := repo.UpdateOrderStatus(ctx, order.ID, shared.OrderStatusConfirmed, nil)
+	if err != nil {
+		return err
+	}
+	if updated.Status != shared.OrderStatusConfirmed || len(updated.Items) != len(order.Items) || updated.TotalAmount != 12.5 {
+		return fmt.Errorf("confirmed order is %+v", *updated)
+	}
+
+	for _, status := range []shared.OrderStatus{shared.OrderStatusDelivered, shared.OrderStatusPending} {
+		_, err = repo.UpdateOrderStatus(ctx, order.ID, status, nil)
+		var transitionErr *shared.InvalidTransitionError
+		if !errors.As(err, &transitionErr) || transitionErr.From != shared.OrderStatusConfirmed || transitionErr.To != status {
+			return fmt.Errorf("moving a confirmed order to %s returned %v, want an *InvalidTransitionError", status, err)
+		}
+	}
+
+	_, err = repo.UpdateOrderStatus(ctx, uuid.New().String(), shared.OrderStatusConfirmed, nil)
+	var notFoundErr *shared.OrderNotFoundError
+	if !errors.As(err, &notFoundErr) {
+		return fmt.Errorf("confirming an unknown order returned %v, want an *OrderNotFoundError", err)
+	}
+	_, err = repo.UpdateOrderStatus(ctx, order.ID, "LOST", nil)
+	var statusErr *shared.InvalidStatusError
+	if !errors.As(err, &statusErr) {
+		return fmt.Errorf("an unknown status returned %v, want an *InvalidStatusError", err)
+	}
+
+	got, err := get(ctx, repo, order.ID)
+	if err != nil {
+		return err
+	}
+	if got.Status != shared.OrderStatusConfirmed {
+		return fmt.Errorf("rejected updates left the order %s", got.Status)
+	}
+	return nil
+}
+
+func ledger(ctx context.Context, repo shared.OrderRepository) error {
+	order := newOrder(uuid.New().String(), time.Now())
+	if err := create(ctx, repo, order); err != nil {
+		return err
+	}
+	consumer := "repotest-" + uuid.New().String()
+
+	confirmed := idempotency.Processed{Consumer: consumer, EventID: uuid.New().String(), Outcome: []byte("confirmed")}
+	if _, err := repo.UpdateOrderStatus(ctx, order.ID, shared.OrderStatusConfirmed, &confirmed); err != nil {
+		return err
+	}
+	_, err := repo.UpdateOrderStatus(ctx, order.ID, shared.OrderStatusCancelled, &confirmed)
+	if !errors.Is(err, idempotency.ErrAlreadyProcessed) {
+		return fmt.Errorf("updating for a processed event returned %v, want %v", err, idempotency.ErrAlreadyProcessed)
+	}
+	got, err := get(ctx, repo, order.ID)
+	if err != nil {
+		return err
+	}
+	if got.Status != shared.OrderStatusConfirmed {
+		return fmt.Errorf("duplicate event moved the order to %s", got.Status)
+	}
+	outcome, err := repo.Outcome(ctx, confirmed)
+	if err != nil {
+		return err
+	}
+	if string(outcome) != string(confirmed.Outcome) {
+		return fmt.Errorf("outcome %q, want %q", outcome, confirmed.Outcome)
+	}
+
+	rejected := idempotency.Processed{Consumer: consumer, EventID: uuid.New().String()}
+	_, err = repo.UpdateOrderStatus(ctx, order.ID, shared.OrderStatusRejected, &rejected)
+	var transitionErr *shared.InvalidTransitionError
+	if !errors.As(err, &transitionErr) {
+		return fmt.Errorf("rejecting a confirmed order returned %v, want an *InvalidTransitionError", err)
+	}
+	if err := repo.Record(ctx, rejected); err != nil {
+		return fmt.Errorf("failed update added its ledger entry: %v", err)
+	}
+	if err := repo.Record(ctx, rejected); !errors.Is(err, idempotency.ErrAlreadyProcessed) {
+		return fmt.Errorf("recording twice returned %v, want %v", err, idempotency.ErrAlreadyProcessed)
+	}
+	return nil
+}
+
+// listAll follows LastKey until the last page of filter.
+func listAll(ctx context.Context, repo shared.OrderRepository, filter shared.OrderFilterInput, limit int32) ([]shared.Order, error) {
+	var orders []shared.Order
+	page, err := repo.ListOrders(ctx, filter, limit, nil)
+	for pages := 1; ; pages++ {
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Orders...)
+		if page.LastKey == nil {
+			return orders, nil
+		}
+		if pages > 100 {
+			return nil, fmt.Errorf("more than %d pages", pages)
+		}
+		page, err = repo.ListOrders(ctx, filter, limit, page.LastKey)
+	}
+}
+
+func ids(orders []shared.Order) []string {
+	result := make([]string, len(orders))
+	for i, order := range orders {
+		result[i] = order.ID
+	}
+	return result
+}
+
+func listCustomer(ctx context.Context, repo shared.OrderRepository) error {
+	customerID := uuid.New().String()
+	start := time.Now().UTC().Truncate(time.Second)
+	var orders []shared.Order
+	for i := 0; i < 5; i++ {
+		orders = append(orders, newOrder(customerID, start.Add(time.Duration(i)*time.Minute)))
+	}
+	if err := create(ctx, repo, orders...); err != nil {
+		return err
+	}
+	if _, err := repo.UpdateOrderStatus(ctx, orders[1].ID, shared.OrderStatusConfirmed, nil); err != nil {
+		return err
+	}
+
+	listed, err := listAll(ctx, repo, shared.OrderFilterInput{CustomerID: customerID}, 2)
+	if err != nil {
+		return err
+	}
+	want := []string{orders[4].ID, orders[3].ID, orders[2].ID, orders[1].ID, orders[0].ID}
+	if fmt.Sprint(ids(listed)) != fmt.Sprint(want) {
+		return fmt.Errorf("listed %v, want %v", ids(listed), want)
+	}
+	for _, order := range listed {
+		if len(order.Items) != 2 || order.TotalAmount != 12.5 {
+			return fmt.Errorf("listed order %s with lines %+v and total %v", order.ID, order.Items, order.TotalAmount)
+		}
+	}
+
+	listed, err = listAll(ctx, repo, shared.OrderFilterInput{
+		CustomerID: customerID,
+		Status:     shared.OrderStatusPending,
+		StartDate:  start.Add(time.Minute).Format(time.RFC3339),
+		EndDate:    start.Add(3 * time.Minute).Format(time.RFC3339),
+	}, 2)
+	if err != nil {
+		return err
+	}
+	want = []string{orders[3].ID, orders[2].ID}
+	if fmt.Sprint(ids(listed)) != fmt.Sprint(want) {
+		return fmt.Errorf("listed %v for pending orders in the range, want %v", ids(listed), want)
+	}
+
+	if _, err := repo.ListOrders(ctx, shared.OrderFilterInput{StartDate: "yesterday"}, 2, nil); !errors.Is(err, shared.ErrInvalidDateRange) {
+		return fmt.Errorf("listing from an invalid date returned %v, want %v", err, shared.ErrInvalidDateRange)
+	}
+	return nil
+}
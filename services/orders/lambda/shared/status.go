@@ -0,0 +1,83 @@
+package shared
+
+import "fmt"
+
+// orderTransitions is the order lifecycle: each status maps to the statuses
+// an order may move to next. DELIVERED, CANCELLED and REJECTED are final.
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderStatusPending:    {OrderStatusConfirmed, OrderStatusRejected, OrderStatusCancelled},
+	OrderStatusConfirmed:  {OrderStatusProcessing, OrderStatusCancelled},
+	OrderStatusProcessing: {OrderStatusShipped, OrderStatusCancelled},
+	OrderStatusShipped:    {OrderStatusDelivered},
+	OrderStatusDelivered:  {},
+	OrderStatusCancelled:  {},
+	OrderStatusRejected:   {},
+}
+
+// Valid reports whether s is one of the OrderStatus constants.
+func (s OrderStatus) Valid() bool {
+	_, ok := orderTransitions[s]
+	return ok
+}
+
+// CanTransitionTo reports whether an order in status s may move to next.
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, status := range orderTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+	return false
+}
+
+// statusesBefore returns the statuses an order may be in to move to next,
+// in declaration order so the generated condition is stable.
+func statusesBefore(next OrderStatus) []OrderStatus {
+	var statuses []OrderStatus
+	for _, status := range []OrderStatus{
+		OrderStatusPending,
+		OrderStatusConfirmed,
+		OrderStatusProcessing,
+		OrderStatusShipped,
+		OrderStatusDelivered,
+		OrderStatusCancelled,
+		OrderStatusRejected,
+	} {
+		if status.CanTransitionTo(next) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+// InvalidStatusError is returned for a status that is not an OrderStatus.
+type InvalidStatusError struct {
+	Status OrderStatus
+}
+
+func (e *InvalidStatusError) Error() string {
+	return fmt.Sprintf("invalid order status %q", e.Status)
+}
+
+// InvalidTransitionError is returned when an order cannot move from its
+// current status to the requested one. The saga handler returns it
+// unwrapped so Step Functions sees its type name as the error.
+type InvalidTransitionError struct {
+	OrderID string
+	From    OrderStatus
+	To      OrderStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("order %s cannot move from %s to %s", e.OrderID, e.From, e.To)
+}
+
+// OrderNotFoundError is returned when a status change targets an order
+// that does not exist.
+type OrderNotFoundError struct {
+	OrderID string
+}
+
+func (e *OrderNotFoundError) Error() string {
+	return fmt.Sprintf("order %s not found", e.OrderID)
+}
@@ -1,10 +1,6 @@
 package shared
 
-import (
-	"strconv"
-
-	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
-)
+import "math"
 
 type AppSyncEvent struct {
 	FieldName  string                 `json:"fieldName"`
@@ -24,58 +20,81 @@ const (
 	OrderStatusShipped    OrderStatus = "SHIPPED"
 	OrderStatusDelivered  OrderStatus = "DELIVERED"
 	OrderStatusCancelled  OrderStatus = "CANCELLED"
+	// OrderStatusRejected marks an order inventory could not reserve stock for.
+	OrderStatusRejected OrderStatus = "REJECTED"
 )
 
+// Order is an order with its lines. The dynamodbav tags are the layout of
+// the order header record, used by MarshalOrder and UnmarshalOrder; the ID
+// is stored in the key and the lines in records of their own.
 type Order struct {
-	ID          string      `json:"id"`
-	CustomerID  string      `json:"customerId"`
-	Status      OrderStatus `json:"status"`
-	Items       []OrderItem `json:"items"`
-	TotalAmount float64     `json:"totalAmount"`
-	CreatedAt   string      `json:"createdAt"`
-	UpdatedAt   string      `json:"updatedAt"`
+	ID          string      `json:"id" dynamodbav:"-"`
+	CustomerID  string      `json:"customerId" dynamodbav:"customer_id"`
+	Status      OrderStatus `json:"status" dynamodbav:"status"`
+	Items       []OrderItem `json:"items" dynamodbav:"-"`
+	TotalAmount float64     `json:"totalAmount" dynamodbav:"total_amount"`
+	CreatedAt   string      `json:"createdAt" dynamodbav:"created_at"`
+	UpdatedAt   string      `json:"updatedAt" dynamodbav:"updated_at"`
 }
 
+// OrderItem is an order line. Its IDs are stored in the key of its record
+// and its total is derived.
 type OrderItem struct {
-	ID        string  `json:"id"`
-	OrderID   string  `json:"orderId"`
-	ItemID    string  `json:"itemId"`
-	Quantity  int     `json:"quantity"`
-	UnitPrice float64 `json:"unitPrice"`
+	ID         string  `json:"id" dynamodbav:"-"`
+	OrderID    string  `json:"orderId" dynamodbav:"-"`
+	ItemID     string  `json:"itemId" dynamodbav:"item_id"`
+	Quantity   int     `json:"quantity" dynamodbav:"quantity"`
+	UnitPrice  float64 `json:"unitPrice" dynamodbav:"unit_price"`
+	TotalPrice float64 `json:"totalPrice" dynamodbav:"-"`
+}
+
+// ComputeTotals derives each line's TotalPrice and the order's TotalAmount
+// from quantities and unit prices, rounded to cents, so clients never see
+// totals that disagree with the lines.
+func (o *Order) ComputeTotals() {
+	total := 0.0
+	for i := range o.Items {
+		o.Items[i].TotalPrice = roundCents(float64(o.Items[i].Quantity) * o.Items[i].UnitPrice)
+		total += o.Items[i].TotalPrice
+	}
+	o.TotalAmount = roundCents(total)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
 }
 
 type OrderFilterInput struct {
 	CustomerID string      `json:"customerId,omitempty"`
-	Status     OrderStatus `json:"status,omitempty"`
+	Status     OrderStatus `json:"status,omitempty" validate:"enum"`
+	StartDate  string      `json:"startDate,omitempty"`
+	EndDate    string      `json:"endDate,omitempty"`
 }
 
+type ListOrdersInput struct {
+	Filter    *OrderFilterInput `json:"filter,omitempty"`
+	Limit     *int              `json:"limit,omitempty"`
+	NextToken *string           `json:"nextToken,omitempty"`
+}
+
+type OrderConnection struct {
+	Items     []Order `json:"items"`
+	NextToken *string `json:"nextToken"`
+}
+
+// CreateOrderInput and UpdateOrderStatusInput are the input arguments of
+// the order mutations. The validate tags are checked by arguments.Decode.
 type CreateOrderInput struct {
-	CustomerID string                 `json:"customerId"`
-	Items      []CreateOrderItemInput `json:"items"`
+	CustomerID string                 `json:"customerId" validate:"required"`
+	Items      []CreateOrderItemInput `json:"items" validate:"required"`
 }
 
 type CreateOrderItemInput struct {
-	ItemID   string `json:"itemId"`
-	Quantity int    `json:"quantity"`
+	ItemID   string `json:"itemId" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1"`
 }
 
 type UpdateOrderStatusInput struct {
-	ID     string      `json:"id"`
-	Status OrderStatus `json:"status"`
-}
-
-func MarshalOrderItems(items []OrderItem) []types.AttributeValue {
-	result := make([]types.AttributeValue, len(items))
-	for i, item := range items {
-		result[i] = &types.AttributeValueMemberM{
-			Value: map[string]types.AttributeValue{
-				"id":        &types.AttributeValueMemberS{Value: item.ID},
-				"orderId":   &types.AttributeValueMemberS{Value: item.OrderID},
-				"itemId":    &types.AttributeValueMemberS{Value: item.ItemID},
-				"quantity":  &types.AttributeValueMemberN{Value: strconv.Itoa(item.Quantity)},
-				"unitPrice": &types.AttributeValueMemberN{Value: strconv.FormatFloat(item.UnitPrice, 'f', 2, 64)},
-			},
-		}
-	}
-	return result
+	OrderID string      `json:"orderId" validate:"required"`
+	Status  OrderStatus `json:"status" validate:"required,enum"`
 }
@@ -0,0 +1,15 @@
+package shared
+
+import (
+	"testing"
+
+	"serp/services/shared/arguments"
+)
+
+func TestValidateTags(t *testing.T) {
+	for _, input := range []interface{}{ListOrdersInput{}, CreateOrderInput{}, UpdateOrderStatusInput{}} {
+		if err := arguments.CheckTags(input); err != nil {
+			t.Error(err)
+		}
+	}
+}
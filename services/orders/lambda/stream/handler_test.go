@@ -0,0 +1,150 @@
+package stream
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"serp/services/orders/lambda/shared"
+	erpevents "serp/services/shared/events"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+)
+
+// publisher records the events put on the bus.
+type publisher struct {
+	events []*erpevents.Event
+}
+
+func (p *publisher) PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error) {
+	for _, entry := range params.Entries {
+		event, err := erpevents.Decode([]byte(aws.ToString(entry.Detail)))
+		if err != nil {
+			return nil, err
+		}
+		p.events = append(p.events, event)
+	}
+	return &eventbridge.PutEventsOutput{}, nil
+}
+
+func header(status shared.OrderStatus) map[string]events.DynamoDBAttributeValue {
+	return map[string]events.DynamoDBAttributeValue{
+		"customer_id": events.NewStringAttribute("customer-1"),
+		"status":      events.NewStringAttribute(string(status)),
+	}
+}
+
+func record(eventID, name, pk, sk string, oldImage, newImage map[string]events.DynamoDBAttributeValue) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventID:   eventID,
+		EventName: name,
+		Change: events.DynamoDBStreamRecord{
+			Keys: map[string]events.DynamoDBAttributeValue{
+				"PK": events.NewStringAttribute(pk),
+				"SK": events.NewStringAttribute(sk),
+			},
+			OldImage:       oldImage,
+			NewImage:       newImage,
+			SequenceNumber: eventID,
+		},
+	}
+}
+
+func TestHandleRequest(t *testing.T) {
+	ctx := context.Background()
+	db := shared.NewMemory()
+	_, err := db.CreateOrder(ctx, shared.Order{
+		ID:         "order-1",
+		CustomerID: "customer-1",
+		Status:     shared.OrderStatusPending,
+		Items: []shared.OrderItem{
+			{ID: "line-1", ItemID: "a", Quantity: 2, UnitPrice: 1},
+			{ID: "line-2", ItemID: "b", Quantity: 1, UnitPrice: 1},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := []erpevents.OrderLine{{ItemID: "a", Quantity: 2}, {ItemID: "b", Quantity: 1}}
+
+	tests := []struct {
+		name    string
+		records []events.DynamoDBEventRecord
+		want    []erpevents.Payload
+	}{
+		{
+			name: "insert publishes one ORDER_CREATED with every line",
+			records: []events.DynamoDBEventRecord{
+				record("1", "INSERT", "ORDER#order-1", "ORDER#order-1", nil, header(shared.OrderStatusPending)),
+				record("2", "INSERT", "ORDER#order-1", "ITEM#line-1", nil, nil),
+				record("3", "INSERT", "ORDER#order-1", "ITEM#line-2", nil, nil),
+				record("4", "INSERT", "IDEMPOTENCY#createOrder#key-1", "IDEMPOTENCY#createOrder#key-1", nil, nil),
+			},
+			want: []erpevents.Payload{
+				erpevents.OrderCreatedEvent{OrderID: "order-1", CustomerID: "customer-1", Items: lines},
+			},
+		},
+		{
+			name: "status change publishes ORDER_STATUS_CHANGED",
+			records: []events.DynamoDBEventRecord{
+				record("5", "MODIFY", "ORDER#order-1", "ORDER#order-1", header(shared.OrderStatusPending), header(shared.OrderStatusConfirmed)),
+			},
+			want: []erpevents.Payload{
+				erpevents.OrderStatusChangedEvent{OrderID: "order-1", PreviousStatus: "PENDING", Status: "CONFIRMED"},
+			},
+		},
+		{
+			name: "cancellation also publishes ORDER_CANCELLED with every line",
+			records: []events.DynamoDBEventRecord{
+				record("6", "MODIFY", "ORDER#order-1", "ORDER#order-1", header(shared.OrderStatusConfirmed), header(shared.OrderStatusCancelled)),
+			},
+			want: []erpevents.Payload{
+				erpevents.OrderStatusChangedEvent{OrderID: "order-1", PreviousStatus: "CONFIRMED", Status: "CANCELLED"},
+				erpevents.OrderCancelledEvent{OrderID: "order-1", Items: lines},
+			},
+		},
+		{
+			name: "other changes publish nothing",
+			records: []events.DynamoDBEventRecord{
+				record("7", "MODIFY", "ORDER#order-1", "ORDER#order-1", header(shared.OrderStatusCancelled), header(shared.OrderStatusCancelled)),
+				record("8", "REMOVE", "ORDER#order-1", "ORDER#order-1", header(shared.OrderStatusCancelled), nil),
+				record("9", "INSERT", "PROCESSED#orders-saga#event-1", "PROCESSED#orders-saga#event-1", nil, nil),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &publisher{}
+			handler := NewHandler(db, bus, "erp-event-bus")
+			batch := events.DynamoDBEvent{Records: tt.records}
+			if response, _ := handler.HandleRequest(ctx, batch); len(response.BatchItemFailures) > 0 {
+				t.Fatalf("batch failed at %v", response.BatchItemFailures)
+			}
+			var got []erpevents.Payload
+			for _, event := range bus.events {
+				got = append(got, event.Payload)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("published %+v, want %+v", got, tt.want)
+			}
+
+			// A retried batch publishes the same event IDs, so consumers
+			// can drop the duplicates.
+			first := bus.events
+			bus.events = nil
+			if response, _ := handler.HandleRequest(ctx, batch); len(response.BatchItemFailures) > 0 {
+				t.Fatalf("retried batch failed at %v", response.BatchItemFailures)
+			}
+			if len(bus.events) != len(first) {
+				t.Fatalf("retried batch published %d events, want %d", len(bus.events), len(first))
+			}
+			for i, event := range bus.events {
+				if event.ID != first[i].ID {
+					t.Errorf("retried event %d has ID %s, want %s", i, event.ID, first[i].ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Package apperror is the error model of the GraphQL API. Handlers return
+// an *Error of one of the kinds below, and Wrap turns it into the response
+// the resolvers' response mapping template raises as an AppSync error with
+// the kind as errorType and Info as errorInfo. Any other error is reported
+// as Internal, and its cause is logged rather than returned.
+package apperror
+
+import "fmt"
+
+// Kind classifies an error for clients. It is the AppSync errorType.
+type Kind string
+
+const (
+	KindNotFound   Kind = "NotFound"
+	KindValidation Kind = "Validation"
+	KindConflict   Kind = "Conflict"
+	KindForbidden  Kind = "Forbidden"
+	KindInternal   Kind = "Internal"
+)
+
+// Error is an error clients may see. Message and Info are returned to the
+// client; Err, the cause, is only logged.
+type Error struct {
+	Kind    Kind
+	Message string
+	Info    map[string]interface{}
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Kind, e.Message, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// With returns a copy of e with key set in its Info.
+func (e *Error) With(key string, value interface{}) *Error {
+	info := make(map[string]interface{}, len(e.Info)+1)
+	for k, v := range e.Info {
+		info[k] = v
+	}
+	info[key] = value
+	copied := *e
+	copied.Info = info
+	return &copied
+}
+
+// NotFound reports that the resource a request names does not exist.
+func NotFound(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// Validation reports arguments that are malformed or break a rule.
+func Validation(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+// Conflict reports a request that is valid but clashes with the current
+// state, such as a disallowed status change or a reused key.
+func Conflict(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+// Forbidden reports a caller that may not make the request.
+func Forbidden(format string, args ...interface{}) *Error {
+	return &Error{Kind: KindForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+// Internal reports a failure of the service itself. Clients only see a
+// generic message; err is logged.
+func Internal(err error) *Error {
+	return &Error{Kind: KindInternal, Message: "internal error", Err: err}
+}
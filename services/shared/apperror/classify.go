@@ -0,0 +1,33 @@
+package apperror
+
+import (
+	"errors"
+
+	"serp/services/shared/arguments"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/pagination"
+)
+
+// classifyShared maps the errors of the shared request handling, which
+// every service returns the same way, to a kind. The rest are left as
+// they are.
+func classifyShared(err error) error {
+	var argsErr *arguments.Error
+	switch {
+	case errors.As(err, &argsErr):
+		return Validation("%v", argsErr).With("fields", fieldInfo(argsErr))
+	case errors.Is(err, pagination.ErrInvalidToken):
+		return Validation("%v", err)
+	case errors.Is(err, idempotency.ErrKeyReused):
+		return Conflict("%v", err)
+	}
+	return err
+}
+
+func fieldInfo(err *arguments.Error) []map[string]string {
+	fields := make([]map[string]string, len(err.Fields))
+	for i, field := range err.Fields {
+		fields[i] = map[string]string{"field": field.Field, "message": field.Message}
+	}
+	return fields
+}
@@ -0,0 +1,27 @@
+module serp/services/shared/apperror
+
+go 1.21
+
+require (
+	serp/services/shared/arguments v0.0.0-00010101000000-000000000000
+	serp/services/shared/idempotency v0.0.0-00010101000000-000000000000
+	serp/services/shared/pagination v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/aws/aws-sdk-go-v2 v1.25.3 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.3 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.3 // indirect
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.30.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/accept-encoding v1.11.1 // indirect
+	github.com/aws/aws-sdk-go-v2/service/internal/endpoint-discovery v1.9.4 // indirect
+	github.com/aws/aws-sdk-go-v2/service/secretsmanager v1.28.2 // indirect
+	github.com/aws/smithy-go v1.20.1 // indirect
+	github.com/jmespath/go-jmespath v0.4.0 // indirect
+)
+
+replace (
+	serp/services/shared/arguments => ../arguments
+	serp/services/shared/idempotency => ../idempotency
+	serp/services/shared/pagination => ../pagination
+)
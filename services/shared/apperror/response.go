@@ -0,0 +1,53 @@
+package apperror
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// Response is the result of a resolver Lambda: Data, or Error if the
+// request failed. The response mapping template raises Error with
+// $util.error and otherwise returns Data as the field's value.
+type Response struct {
+	Data  interface{}    `json:"data"`
+	Error *ResponseError `json:"error,omitempty"`
+}
+
+// ResponseError carries the message, errorType and errorInfo of a failed
+// request.
+type ResponseError struct {
+	Message string                 `json:"message"`
+	Type    Kind                   `json:"type"`
+	Info    map[string]interface{} `json:"info,omitempty"`
+}
+
+// Wrap adapts an AppSync handler to return a Response. classify turns the
+// handler's domain errors into *Error values; Wrap itself maps the errors
+// of argument decoding, pagination and idempotency keys. Errors left as
+// they are become Internal, which are logged with their cause.
+func Wrap[E any](handle func(ctx context.Context, event E) (interface{}, error), classify func(err error) error) func(ctx context.Context, event E) (Response, error) {
+	return func(ctx context.Context, event E) (Response, error) {
+		data, err := handle(ctx, event)
+		if err == nil {
+			return Response{Data: data}, nil
+		}
+		if classify != nil {
+			err = classify(err)
+		}
+		err = classifyShared(err)
+
+		var appErr *Error
+		if !errors.As(err, &appErr) {
+			appErr = Internal(err)
+		}
+		if appErr.Kind == KindInternal {
+			log.Printf("internal error: %v", appErr.Err)
+		}
+		return Response{Error: &ResponseError{
+			Message: appErr.Message,
+			Type:    appErr.Kind,
+			Info:    appErr.Info,
+		}}, nil
+	}
+}
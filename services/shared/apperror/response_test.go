@@ -0,0 +1,115 @@
+package apperror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"serp/services/shared/arguments"
+	"serp/services/shared/idempotency"
+	"serp/services/shared/pagination"
+)
+
+var errNotFound = errors.New("widget not found")
+
+// classify is a service's classification of its domain errors.
+func classify(err error) error {
+	if errors.Is(err, errNotFound) {
+		return NotFound("%v", err).With("id", "w-1")
+	}
+	return err
+}
+
+func TestWrap(t *testing.T) {
+	argsErr := &arguments.Error{Fields: []arguments.FieldError{{Field: "items[0].quantity", Message: "must be at least 1"}}}
+	tests := []struct {
+		name string
+		err  error
+		want ResponseError
+	}{
+		{
+			name: "domain error",
+			err:  fmt.Errorf("failed to get widget: %w", errNotFound),
+			want: ResponseError{Message: "failed to get widget: widget not found", Type: KindNotFound, Info: map[string]interface{}{"id": "w-1"}},
+		},
+		{
+			name: "invalid arguments",
+			err:  argsErr,
+			want: ResponseError{
+				Message: argsErr.Error(),
+				Type:    KindValidation,
+				Info: map[string]interface{}{"fields": []map[string]string{
+					{"field": "items[0].quantity", "message": "must be at least 1"},
+				}},
+			},
+		},
+		{
+			name: "invalid token",
+			err:  fmt.Errorf("failed to list widgets: %w", pagination.ErrInvalidToken),
+			want: ResponseError{Message: "failed to list widgets: invalid nextToken", Type: KindValidation},
+		},
+		{
+			name: "reused key",
+			err:  idempotency.ErrKeyReused,
+			want: ResponseError{Message: idempotency.ErrKeyReused.Error(), Type: KindConflict},
+		},
+		{
+			name: "already classified",
+			err:  Forbidden("not yours"),
+			want: ResponseError{Message: "not yours", Type: KindForbidden},
+		},
+		{
+			name: "unclassified",
+			err:  errors.New("failed to query table: secret connection string"),
+			want: ResponseError{Message: "internal error", Type: KindInternal},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handle := Wrap(func(ctx context.Context, event string) (interface{}, error) {
+				return nil, tt.err
+			}, classify)
+			response, err := handle(context.Background(), "event")
+			if err != nil {
+				t.Fatalf("Wrap returned %v", err)
+			}
+			if response.Data != nil || response.Error == nil {
+				t.Fatalf("response %+v, want only an error", response)
+			}
+			if !reflect.DeepEqual(*response.Error, tt.want) {
+				t.Errorf("error %+v, want %+v", *response.Error, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapHidesInternalCause(t *testing.T) {
+	handle := Wrap(func(ctx context.Context, event string) (interface{}, error) {
+		return nil, errors.New("secret connection string")
+	}, nil)
+	response, _ := handle(context.Background(), "event")
+	if response.Error == nil || response.Error.Type != KindInternal || response.Error.Message != "internal error" {
+		t.Fatalf("response error %+v, want Internal with the generic message", response.Error)
+	}
+	body, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(body), "secret") {
+		t.Errorf("response %s contains the cause", body)
+	}
+}
+
+func TestWrapData(t *testing.T) {
+	handle := Wrap(func(ctx context.Context, event string) (interface{}, error) {
+		return "widget", nil
+	}, classify)
+	response, err := handle(context.Background(), "event")
+	if err != nil || response.Data != "widget" || response.Error != nil {
+		t.Errorf("Wrap returned %+v, %v, want the data", response, err)
+	}
+}
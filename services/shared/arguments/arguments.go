@@ -0,0 +1,314 @@
+// Package arguments decodes AppSync resolver arguments into typed input
+// structs and validates them against their validate tags, reporting every
+// invalid field by its path in the arguments.
+//
+// The rules are, separated by commas:
+//
+//	required  the value is present and not empty
+//	min=N     a number is at least N, or a string or list has at least N elements
+//	max=N     a number is at most N, or a string or list has at most N elements
+//	enum      a non-empty value's Valid method reports true
+//	nonblank  an Optional that is supplied is not null, a blank string or an empty list
+//
+// Rules other than required skip absent values. Nested structs, pointers
+// to structs and lists of structs are validated too.
+package arguments
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// FieldError is a field of the arguments that failed to decode or
+// validate. Field is its path, e.g. input.items[0].quantity.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+// Error lists the invalid fields of a request.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Error()
+	}
+	return "invalid arguments: " + strings.Join(messages, "; ")
+}
+
+// Decode copies args into input, a pointer to a struct, and validates it.
+// It returns an *Error if a field has the wrong type or breaks a rule.
+func Decode(args map[string]interface{}, input interface{}) error {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("failed to encode arguments: %v", err)
+	}
+	// A field of the wrong type is left unset while the others are still
+	// decoded, so it is reported along with the fields that break a rule.
+	var fields []FieldError
+	if err := json.Unmarshal(data, input); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			return fmt.Errorf("failed to decode arguments: %v", err)
+		}
+		fields = append(fields, FieldError{Field: jsonPath(typeErr.Field), Message: "must be " + kind(typeErr.Type)})
+	}
+
+	var invalid *Error
+	if err := Validate(input); errors.As(err, &invalid) {
+		for _, field := range invalid.Fields {
+			if len(fields) == 0 || field.Field != fields[0].Field {
+				fields = append(fields, field)
+			}
+		}
+	} else if err != nil {
+		return err
+	}
+	if len(fields) > 0 {
+		return &Error{Fields: fields}
+	}
+	return nil
+}
+
+// listIndex matches the list indexes in an encoding/json field path.
+var listIndex = regexp.MustCompile(`\.(\d+)\b`)
+
+// jsonPath writes the list indexes of an encoding/json field path, as in
+// items.0.quantity, the way Validate does: items[0].quantity.
+func jsonPath(field string) string {
+	return listIndex.ReplaceAllString(field, "[$1]")
+}
+
+// Validate checks input, a struct or a pointer to one, against its
+// validate tags. It fails without an *Error if a tag is malformed.
+func Validate(input interface{}) error {
+	if err := checkTagsOf(reflect.TypeOf(input)); err != nil {
+		return err
+	}
+	var fields []FieldError
+	validateValue(reflect.ValueOf(input), "", &fields)
+	if len(fields) > 0 {
+		return &Error{Fields: fields}
+	}
+	return nil
+}
+
+func kind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	default:
+		return "an object"
+	}
+}
+
+// validateValue applies the tags of a struct's fields and descends into
+// the values they hold.
+func validateValue(v reflect.Value, path string, fields *[]FieldError) {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if o, ok := v.Interface().(optional); ok {
+		value, set, null, typeErr := o.optional()
+		if set && !null && typeErr == nil {
+			validateValue(value, path, fields)
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := fieldName(field)
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			fieldPath := name
+			if path != "" {
+				fieldPath = path + "." + name
+			}
+			// The tags were checked by Validate, so they parse.
+			rules, _ := parseRules(field.Tag.Get("validate"))
+			if message := check(v.Field(i), rules); message != "" {
+				*fields = append(*fields, FieldError{Field: fieldPath, Message: message})
+				continue
+			}
+			validateValue(v.Field(i), fieldPath, fields)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			validateValue(v.Index(i), fmt.Sprintf("%s[%d]", path, i), fields)
+		}
+	}
+}
+
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// rule is one rule of a validate tag. limit is the argument of min and max.
+type rule struct {
+	name  string
+	arg   string
+	limit float64
+}
+
+// parseRules splits a validate tag into its rules.
+func parseRules(tag string) ([]rule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	var rules []rule
+	for _, text := range strings.Split(tag, ",") {
+		name, arg, hasArg := strings.Cut(text, "=")
+		r := rule{name: name, arg: arg}
+		switch name {
+		case "required", "nonblank", "enum":
+			if hasArg {
+				return nil, fmt.Errorf("rule %q takes no argument", name)
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q needs a numeric limit", text)
+			}
+			r.limit = limit
+		default:
+			return nil, fmt.Errorf("unknown rule %q", text)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// check applies the rules of a validate tag to a value and returns why the
+// value breaks them, or "".
+func check(v reflect.Value, rules []rule) string {
+	if len(rules) == 0 {
+		return ""
+	}
+	if o, ok := v.Interface().(optional); ok {
+		value, set, null, typeErr := o.optional()
+		switch {
+		case typeErr != nil:
+			return "must be " + kind(typeErr.Type)
+		case !set:
+			return missing(rules)
+		case null && hasRule(rules, "nonblank"):
+			return "must not be null"
+		case null:
+			return ""
+		case absent(value) && hasRule(rules, "nonblank"):
+			return "must not be blank"
+		}
+		v = value
+	} else if absent(v) {
+		return missing(rules)
+	}
+	for v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	for _, r := range rules {
+		var message string
+		switch r.name {
+		case "min":
+			message = bound(v, r, "at least", func(value, limit float64) bool { return value >= limit })
+		case "max":
+			message = bound(v, r, "at most", func(value, limit float64) bool { return value <= limit })
+		case "enum":
+			if enum, ok := v.Interface().(interface{ Valid() bool }); ok && !enum.Valid() {
+				message = fmt.Sprintf("must be a valid %s, got %q", v.Type().Name(), fmt.Sprint(v.Interface()))
+			}
+		}
+		if message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+// missing returns why an absent value breaks rules, or "".
+func missing(rules []rule) string {
+	if hasRule(rules, "required") {
+		return "is required"
+	}
+	return ""
+}
+
+func hasRule(rules []rule, name string) bool {
+	for _, r := range rules {
+		if r.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// absent reports whether a value is missing: a nil pointer, blank string
+// or empty list.
+func absent(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Map:
+		return v.IsNil() || (v.Kind() == reflect.Map && v.Len() == 0)
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice:
+		return v.Len() == 0
+	}
+	return false
+}
+
+// bound compares a number, or the length of a string or list, with the
+// limit of r.
+func bound(v reflect.Value, r rule, relation string, ok func(value, limit float64) bool) string {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !ok(float64(v.Int()), r.limit) {
+			return fmt.Sprintf("must be %s %s", relation, r.arg)
+		}
+	case reflect.Float32, reflect.Float64:
+		if !ok(v.Float(), r.limit) {
+			return fmt.Sprintf("must be %s %s", relation, r.arg)
+		}
+	case reflect.String:
+		if !ok(float64(len([]rune(v.String()))), r.limit) {
+			return fmt.Sprintf("must have %s %s characters", relation, r.arg)
+		}
+	case reflect.Slice, reflect.Array:
+		if !ok(float64(v.Len()), r.limit) {
+			return fmt.Sprintf("must have %s %s items", relation, r.arg)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,184 @@
+package arguments
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type color string
+
+func (c color) Valid() bool { return c == "RED" || c == "GREEN" }
+
+type line struct {
+	ItemID   string `json:"itemId" validate:"required"`
+	Quantity int    `json:"quantity" validate:"min=1,max=10"`
+}
+
+type order struct {
+	Name    string           `json:"name" validate:"required,min=2,max=5"`
+	Price   float64          `json:"price" validate:"min=0,max=100"`
+	Count   *int             `json:"count" validate:"min=1"`
+	Tags    []string         `json:"tags" validate:"max=2"`
+	Color   color            `json:"color" validate:"enum"`
+	Items   []line           `json:"items" validate:"required,min=1"`
+	Groups  [][]line         `json:"groups"`
+	Note    Optional[string] `json:"note" validate:"nonblank"`
+	Limit   Optional[int]    `json:"limit" validate:"nonblank,min=1"`
+	Comment Optional[string] `json:"comment" validate:"max=3"`
+}
+
+// valid returns arguments that decode into an order breaking no rule.
+func valid() map[string]interface{} {
+	return map[string]interface{}{
+		"name":  "abc",
+		"price": 10,
+		"color": "RED",
+		"items": []interface{}{map[string]interface{}{"itemId": "a", "quantity": 1}},
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(args map[string]interface{})
+		want []FieldError
+	}{
+		{"valid", func(map[string]interface{}) {}, nil},
+		{"missing string", func(a map[string]interface{}) { delete(a, "name") }, []FieldError{{"name", "is required"}}},
+		{"blank string", func(a map[string]interface{}) { a["name"] = "  " }, []FieldError{{"name", "is required"}}},
+		{"short string", func(a map[string]interface{}) { a["name"] = "a" }, []FieldError{{"name", "must have at least 2 characters"}}},
+		{"long string", func(a map[string]interface{}) { a["name"] = "abcdef" }, []FieldError{{"name", "must have at most 5 characters"}}},
+		{"string counted in characters", func(a map[string]interface{}) { a["name"] = "ééééé" }, nil},
+		{"number below min", func(a map[string]interface{}) { a["price"] = -0.5 }, []FieldError{{"price", "must be at least 0"}}},
+		{"number above max", func(a map[string]interface{}) { a["price"] = 100.5 }, []FieldError{{"price", "must be at most 100"}}},
+		{"number at the limits", func(a map[string]interface{}) { a["price"] = 100 }, nil},
+		{"pointer below min", func(a map[string]interface{}) { a["count"] = 0 }, []FieldError{{"count", "must be at least 1"}}},
+		{"nil pointer", func(a map[string]interface{}) { a["count"] = nil }, nil},
+		{"long list", func(a map[string]interface{}) { a["tags"] = []interface{}{"a", "b", "c"} }, []FieldError{{"tags", "must have at most 2 items"}}},
+		{"missing list", func(a map[string]interface{}) { delete(a, "items") }, []FieldError{{"items", "is required"}}},
+		{"empty list", func(a map[string]interface{}) { a["items"] = []interface{}{} }, []FieldError{{"items", "is required"}}},
+		{"invalid enum", func(a map[string]interface{}) { a["color"] = "BLUE" }, []FieldError{{"color", `must be a valid color, got "BLUE"`}}},
+		{"absent enum", func(a map[string]interface{}) { delete(a, "color") }, nil},
+		{"optional absent", func(map[string]interface{}) {}, nil},
+		{"optional null", func(a map[string]interface{}) { a["note"] = nil }, []FieldError{{"note", "must not be null"}}},
+		{"optional blank", func(a map[string]interface{}) { a["note"] = " " }, []FieldError{{"note", "must not be blank"}}},
+		{"optional set", func(a map[string]interface{}) { a["note"] = "x" }, nil},
+		{"optional below min", func(a map[string]interface{}) { a["limit"] = 0 }, []FieldError{{"limit", "must be at least 1"}}},
+		{"optional of the wrong type", func(a map[string]interface{}) { a["limit"] = "ten" }, []FieldError{{"limit", "must be an integer"}}},
+		{"nullable optional null", func(a map[string]interface{}) { a["comment"] = nil }, nil},
+		{"nullable optional too long", func(a map[string]interface{}) { a["comment"] = "abcd" }, []FieldError{{"comment", "must have at most 3 characters"}}},
+		{
+			"nested list element",
+			func(a map[string]interface{}) {
+				a["items"] = []interface{}{
+					map[string]interface{}{"itemId": "a", "quantity": 1},
+					map[string]interface{}{"quantity": 11},
+				}
+			},
+			[]FieldError{{"items[1].itemId", "is required"}, {"items[1].quantity", "must be at most 10"}},
+		},
+		{
+			"nested slice of structs",
+			func(a map[string]interface{}) {
+				a["groups"] = []interface{}{
+					[]interface{}{map[string]interface{}{"itemId": "a", "quantity": 1}},
+					[]interface{}{map[string]interface{}{"itemId": "b", "quantity": 0}},
+				}
+			},
+			[]FieldError{{"groups[1][0].quantity", "must be at least 1"}},
+		},
+		{
+			"wrong type alongside rule failures",
+			func(a map[string]interface{}) {
+				a["name"] = ""
+				a["items"] = []interface{}{map[string]interface{}{"itemId": "", "quantity": "two"}}
+			},
+			[]FieldError{{"items[0].quantity", "must be an integer"}, {"name", "is required"}, {"items[0].itemId", "is required"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := valid()
+			tt.edit(args)
+			var input order
+			err := Decode(args, &input)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("Decode returned %v", err)
+				}
+				return
+			}
+			var invalid *Error
+			if !errors.As(err, &invalid) {
+				t.Fatalf("Decode returned %v, want an *Error", err)
+			}
+			if !reflect.DeepEqual(invalid.Fields, tt.want) {
+				t.Errorf("Decode reported %v, want %v", invalid.Fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTags(t *testing.T) {
+	if err := CheckTags(order{}); err != nil {
+		t.Errorf("CheckTags(order) returned %v", err)
+	}
+
+	type nested struct {
+		Lines []struct {
+			Quantity int `json:"quantity" validate:"minimum=1"`
+		} `json:"lines"`
+	}
+	tests := []struct {
+		name  string
+		input interface{}
+		err   string
+	}{
+		{"unknown rule", struct {
+			Name string `validate:"requried"`
+		}{}, `field Name of struct { Name string "validate:\"requried\"" }: unknown rule "requried"`},
+		{"bad limit", struct {
+			Name string `json:"name" validate:"min=one"`
+		}{}, `rule "min=one" needs a numeric limit`},
+		{"missing limit", struct {
+			Name string `json:"name" validate:"max"`
+		}{}, `rule "max" needs a numeric limit`},
+		{"argument to a flag", struct {
+			Name string `json:"name" validate:"required=true"`
+		}{}, `rule "required" takes no argument`},
+		{"limit on a struct", struct {
+			Line line `json:"line" validate:"min=1"`
+		}{}, `rule "min" needs a number, string or list`},
+		{"enum without Valid", struct {
+			Name string `json:"name" validate:"enum"`
+		}{}, `rule "enum" needs a Valid method`},
+		{"nonblank without Optional", struct {
+			Name string `json:"name" validate:"nonblank"`
+		}{}, `rule "nonblank" needs an Optional`},
+		{"nested list of structs", &nested{}, `field lines.quantity of`},
+		{"inside an Optional", struct {
+			Line Optional[*nested] `json:"line"`
+		}{}, `field line.lines.quantity of`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTags(tt.input)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("CheckTags returned %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestDecodeMalformedTag(t *testing.T) {
+	var input struct {
+		Name string `json:"name" validate:"requried"`
+	}
+	err := Decode(map[string]interface{}{"name": "a"}, &input)
+	var invalid *Error
+	if err == nil || errors.As(err, &invalid) {
+		t.Errorf("Decode with a malformed tag returned %v, want an error that is not an *Error", err)
+	}
+}
@@ -0,0 +1,3 @@
+module serp/services/shared/arguments
+
+go 1.21
@@ -0,0 +1,54 @@
+package arguments
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+)
+
+// Optional is an argument that distinguishes being left out from being
+// null: Set reports whether it was supplied at all and Null whether it was
+// supplied as null. A field of type Optional counts as absent for the
+// validate rules unless it is set to a value, and its rules apply to that
+// value.
+type Optional[T any] struct {
+	Value T
+	Set   bool
+	Null  bool
+
+	// typeErr is kept for Validate, which reports it under the field's
+	// path; encoding/json loses the path of errors from UnmarshalJSON.
+	typeErr *json.UnmarshalTypeError
+}
+
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	var value T
+	o.Value, o.Set, o.Null, o.typeErr = value, true, string(data) == "null", nil
+	if o.Null {
+		return nil
+	}
+	err := json.Unmarshal(data, &o.Value)
+	if errors.As(err, &o.typeErr) {
+		return nil
+	}
+	return err
+}
+
+// Ptr returns nil if o was left out, a pointer to the zero value if it is
+// null and a pointer to its value otherwise.
+func (o Optional[T]) Ptr() *T {
+	if !o.Set {
+		return nil
+	}
+	value := o.Value
+	return &value
+}
+
+func (o Optional[T]) optional() (value reflect.Value, set, null bool, typeErr *json.UnmarshalTypeError) {
+	return reflect.ValueOf(o.Value), o.Set, o.Null, o.typeErr
+}
+
+// optional is implemented by every Optional type, for Validate.
+type optional interface {
+	optional() (value reflect.Value, set, null bool, typeErr *json.UnmarshalTypeError)
+}
@@ -0,0 +1,102 @@
+package arguments
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// checked caches the result of checking the tags of each input type.
+var checked sync.Map
+
+// CheckTags fails if a validate tag of input, a struct or a pointer to
+// one, or of a struct it holds, is malformed or names a rule its field
+// cannot have. Validate runs the check once per type, so a bad tag fails
+// every request instead of crashing; call CheckTags from a test of each
+// input type to catch it before deploying.
+func CheckTags(input interface{}) error {
+	return checkTagsOf(reflect.TypeOf(input))
+}
+
+func checkTagsOf(t reflect.Type) error {
+	if err, ok := checked.Load(t); ok {
+		return toError(err)
+	}
+	err := checkType(t, "", map[reflect.Type]bool{})
+	checked.Store(t, err)
+	return err
+}
+
+func toError(value interface{}) error {
+	err, _ := value.(error)
+	return err
+}
+
+var (
+	optionalType = reflect.TypeOf((*optional)(nil)).Elem()
+	enumType     = reflect.TypeOf((*interface{ Valid() bool })(nil)).Elem()
+)
+
+// valueType returns the type the rules of a field of type t apply to: the
+// value of an Optional, without pointers.
+func valueType(t reflect.Type) reflect.Type {
+	if t.Implements(optionalType) {
+		value, _ := t.FieldByName("Value")
+		t = value.Type
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+func checkType(t reflect.Type, path string, seen map[reflect.Type]bool) error {
+	if t == nil {
+		return nil
+	}
+	t = valueType(t)
+	for t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = valueType(t.Elem())
+	}
+	if t.Kind() != reflect.Struct || seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := fieldName(field)
+		if !field.IsExported() || name == "-" {
+			continue
+		}
+		fieldPath := path + name
+		rules, err := parseRules(field.Tag.Get("validate"))
+		if err != nil {
+			return fmt.Errorf("arguments: field %s of %s: %v", fieldPath, t, err)
+		}
+		value := valueType(field.Type)
+		for _, r := range rules {
+			switch {
+			case (r.name == "min" || r.name == "max") && !bounded(value):
+				return fmt.Errorf("arguments: field %s of %s: rule %q needs a number, string or list", fieldPath, t, r.name)
+			case r.name == "enum" && !value.Implements(enumType):
+				return fmt.Errorf("arguments: field %s of %s: rule %q needs a Valid method", fieldPath, t, r.name)
+			case r.name == "nonblank" && !field.Type.Implements(optionalType):
+				return fmt.Errorf("arguments: field %s of %s: rule %q needs an Optional", fieldPath, t, r.name)
+			}
+		}
+		if err := checkType(field.Type, fieldPath+".", seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func bounded(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Slice, reflect.Array:
+		return true
+	}
+	return false
+}
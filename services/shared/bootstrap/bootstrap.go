@@ -0,0 +1,103 @@
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+// Env is the function configuration set by NewMicroserviceStack.
+type Env struct {
+	ServiceName     string
+	TableName       string
+	EventBusName    string
+	CursorSecretArn string
+}
+
+// Clients holds everything a handler needs, created once per cold start.
+type Clients struct {
+	Config         aws.Config
+	Env            Env
+	DynamoDB       *dynamodb.Client
+	EventBridge    *eventbridge.Client
+	SecretsManager *secretsmanager.Client
+}
+
+func LoadEnv() (Env, error) {
+	env := Env{
+		ServiceName:     os.Getenv("SERVICE_NAME"),
+		TableName:       os.Getenv("TABLE_NAME"),
+		EventBusName:    os.Getenv("EVENT_BUS_NAME"),
+		CursorSecretArn: os.Getenv("CURSOR_SECRET_ARN"),
+	}
+	if env.TableName == "" {
+		return Env{}, fmt.Errorf("TABLE_NAME environment variable is not set")
+	}
+	if env.EventBusName == "" {
+		return Env{}, fmt.Errorf("EVENT_BUS_NAME environment variable is not set")
+	}
+	return env, nil
+}
+
+// TableOf returns the table name of another service whose table the
+// function reads, set by MicroserviceStackProps.ReadsTablesOf.
+func (e Env) TableOf(service string) (string, error) {
+	variable := strings.ToUpper(service) + "_TABLE_NAME"
+	name := os.Getenv(variable)
+	if name == "" {
+		return "", fmt.Errorf("%s environment variable is not set", variable)
+	}
+	return name, nil
+}
+
+func NewClients(ctx context.Context) (*Clients, error) {
+	env, err := LoadEnv()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %v", err)
+	}
+	return &Clients{
+		Config:         cfg,
+		Env:            env,
+		DynamoDB:       dynamodb.NewFromConfig(cfg),
+		EventBridge:    eventbridge.NewFromConfig(cfg),
+		SecretsManager: secretsmanager.NewFromConfig(cfg),
+	}, nil
+}
+
+// Start creates the clients, builds the handler with newHandler and hands
+// it to the Lambda runtime. newHandler returns any handler signature
+// accepted by lambda.Start. A cold-start failure does not crash the
+// runtime: it is logged and returned from every invocation, so it reaches
+// the caller instead of surfacing as an opaque Runtime.ExitError.
+func Start(newHandler func(ctx context.Context, c *Clients) (interface{}, error)) {
+	handler, err := build(context.Background(), newHandler)
+	if err != nil {
+		log.Printf("cold start failed: %v", err)
+		lambda.Start(func(ctx context.Context) error {
+			return fmt.Errorf("function failed to initialise: %v", err)
+		})
+		return
+	}
+	lambda.Start(handler)
+}
+
+func build(ctx context.Context, newHandler func(ctx context.Context, c *Clients) (interface{}, error)) (interface{}, error) {
+	clients, err := NewClients(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newHandler(ctx, clients)
+}
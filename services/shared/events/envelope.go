@@ -0,0 +1,146 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+const timestampLayout = time.RFC3339Nano
+
+var (
+	ErrUnknownType    = errors.New("unknown event type")
+	ErrUnknownVersion = errors.New("unknown event version")
+)
+
+// Payload is the typed body of an event. Every payload type is listed in
+// the catalog with its source and version.
+type Payload interface {
+	EventType() string
+}
+
+// Envelope is the EventBridge detail of every event on erp-event-bus.
+// CorrelationID is shared by all events descending from the same original
+// event, and CausationID is the ID of the event that directly caused this
+// one.
+type Envelope struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	Version       int             `json:"version"`
+	Source        string          `json:"source"`
+	CorrelationID string          `json:"correlationId"`
+	CausationID   string          `json:"causationId,omitempty"`
+	Timestamp     string          `json:"timestamp"`
+	Data          json.RawMessage `json:"data"`
+}
+
+// Event is a decoded envelope with its typed payload.
+type Event struct {
+	ID            string
+	Type          string
+	Version       int
+	Source        string
+	CorrelationID string
+	CausationID   string
+	Timestamp     time.Time
+	Payload       Payload
+}
+
+// New returns an event starting a new correlation chain. id must be stable
+// for the change the event describes, so republishing the change yields
+// an event consumers can recognise as a duplicate.
+func New(id string, payload Payload) *Event {
+	def := catalog[payload.EventType()]
+	return &Event{
+		ID:            id,
+		Type:          payload.EventType(),
+		Version:       def.version,
+		Source:        def.source,
+		CorrelationID: id,
+		Timestamp:     time.Now().UTC(),
+		Payload:       payload,
+	}
+}
+
+// CausedBy returns an event published in reaction to cause, continuing its
+// correlation chain.
+func CausedBy(cause *Event, id string, payload Payload) *Event {
+	event := New(id, payload)
+	event.CorrelationID = cause.CorrelationID
+	event.CausationID = cause.ID
+	return event
+}
+
+// Encode returns the EventBridge detail for event. The event's DetailType
+// on the bus must be event.Type.
+func Encode(event *Event) ([]byte, error) {
+	def, ok := catalog[event.Type]
+	if !ok || event.Payload == nil || event.Payload.EventType() != event.Type {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownType, event.Type)
+	}
+	if event.Version != def.version {
+		return nil, fmt.Errorf("%w: %s version %d", ErrUnknownVersion, event.Type, event.Version)
+	}
+
+	data, err := json.Marshal(event.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal %s payload: %v", event.Type, err)
+	}
+	return json.Marshal(Envelope{
+		ID:            event.ID,
+		Type:          event.Type,
+		Version:       event.Version,
+		Source:        event.Source,
+		CorrelationID: event.CorrelationID,
+		CausationID:   event.CausationID,
+		Timestamp:     event.Timestamp.Format(timestampLayout),
+		Data:          data,
+	})
+}
+
+// Decode parses an EventBridge detail into an event whose Payload holds the
+// catalog's payload type for the event type, as a value. Events of unknown
+// types or versions, or from a source other than the catalog's, are
+// rejected.
+func Decode(detail []byte) (*Event, error) {
+	var envelope Envelope
+	if err := json.Unmarshal(detail, &envelope); err != nil {
+		return nil, fmt.Errorf("invalid event envelope: %v", err)
+	}
+
+	def, ok := catalog[envelope.Type]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownType, envelope.Type)
+	}
+	if envelope.Version != def.version {
+		return nil, fmt.Errorf("%w: %s version %d", ErrUnknownVersion, envelope.Type, envelope.Version)
+	}
+	if envelope.Source != def.source {
+		return nil, fmt.Errorf("event %s has source %q, expected %q", envelope.Type, envelope.Source, def.source)
+	}
+	if envelope.ID == "" {
+		return nil, fmt.Errorf("event %s has no id", envelope.Type)
+	}
+	timestamp, err := time.Parse(timestampLayout, envelope.Timestamp)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamp of event %s: %v", envelope.ID, err)
+	}
+
+	payload := reflect.New(reflect.TypeOf(def.payload))
+	if err := json.Unmarshal(envelope.Data, payload.Interface()); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %v", envelope.Type, err)
+	}
+
+	return &Event{
+		ID:            envelope.ID,
+		Type:          envelope.Type,
+		Version:       envelope.Version,
+		Source:        envelope.Source,
+		CorrelationID: envelope.CorrelationID,
+		CausationID:   envelope.CausationID,
+		Timestamp:     timestamp,
+		Payload:       payload.Elem().Interface().(Payload),
+	}, nil
+}
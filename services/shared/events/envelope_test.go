@@ -0,0 +1,128 @@
+package events
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var samples = []Payload{
+	OrderCreatedEvent{OrderID: "order-1", CustomerID: "customer-1", Items: []OrderLine{{ItemID: "a", Quantity: 2}, {ItemID: "b", Quantity: 1}}},
+	OrderCancelledEvent{OrderID: "order-1", Items: []OrderLine{{ItemID: "a", Quantity: 2}}},
+	OrderStatusChangedEvent{OrderID: "order-1", PreviousStatus: "PENDING", Status: "CONFIRMED"},
+	ItemCreatedEvent{ItemID: "a", Name: "widget", Quantity: 5},
+	ItemUpdatedEvent{ItemID: "a", Name: "widget", PreviousQuantity: 5, Quantity: 3},
+	ItemDeletedEvent{ItemID: "a"},
+	InventoryUpdatedEvent{OrderID: "order-1", Items: []OrderLine{{ItemID: "a", Quantity: 2}}},
+	InsufficientInventoryEvent{OrderID: "order-1", Shortfalls: []Shortfall{{ItemID: "a", Requested: 2, Available: 1}, {ItemID: "b", Requested: 1, Missing: true}}},
+	InventoryRestoredEvent{OrderID: "order-1", Items: []OrderLine{{ItemID: "a", Quantity: 2}}},
+}
+
+func sampleEvent(payload Payload) *Event {
+	cause := New("cause-1", OrderCreatedEvent{OrderID: "order-1"})
+	event := CausedBy(cause, "event-1", payload)
+	event.Timestamp = time.Date(2024, 5, 1, 10, 0, 0, 123456789, time.UTC)
+	return event
+}
+
+func TestRoundTrip(t *testing.T) {
+	covered := make(map[string]bool)
+	for _, payload := range samples {
+		t.Run(payload.EventType(), func(t *testing.T) {
+			covered[payload.EventType()] = true
+			event := sampleEvent(payload)
+			detail, err := Encode(event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := Decode(detail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, event) {
+				t.Errorf("decoded %+v, want %+v", decoded, event)
+			}
+		})
+	}
+	for eventType := range catalog {
+		if !covered[eventType] {
+			t.Errorf("no sample for catalog entry %s", eventType)
+		}
+	}
+}
+
+// envelope encodes a valid event and lets edit change its envelope.
+func envelope(t *testing.T, edit func(*Envelope)) []byte {
+	t.Helper()
+	detail, err := Encode(sampleEvent(ItemDeletedEvent{ItemID: "a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var env Envelope
+	if err := json.Unmarshal(detail, &env); err != nil {
+		t.Fatal(err)
+	}
+	edit(&env)
+	detail, err = json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return detail
+}
+
+func TestEncodeRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(*Event)
+		want error
+	}{
+		{"unknown type", func(e *Event) { e.Type = "ITEM_RENAMED" }, ErrUnknownType},
+		{"payload of another type", func(e *Event) { e.Payload = ItemCreatedEvent{ItemID: "a"} }, ErrUnknownType},
+		{"no payload", func(e *Event) { e.Payload = nil }, ErrUnknownType},
+		{"newer version", func(e *Event) { e.Version++ }, ErrUnknownVersion},
+		{"older version", func(e *Event) { e.Version-- }, ErrUnknownVersion},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := sampleEvent(ItemDeletedEvent{ItemID: "a"})
+			tt.edit(event)
+			if _, err := Encode(event); !errors.Is(err, tt.want) {
+				t.Errorf("Encode returned %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		detail []byte
+		want   error
+		// message is part of the error when want is nil.
+		message string
+	}{
+		{name: "unknown type", detail: envelope(t, func(e *Envelope) { e.Type = "ITEM_RENAMED" }), want: ErrUnknownType},
+		{name: "newer version", detail: envelope(t, func(e *Envelope) { e.Version++ }), want: ErrUnknownVersion},
+		{name: "older version", detail: envelope(t, func(e *Envelope) { e.Version-- }), want: ErrUnknownVersion},
+		{name: "wrong source", detail: envelope(t, func(e *Envelope) { e.Source = SourceOrders }), message: "has source"},
+		{name: "missing id", detail: envelope(t, func(e *Envelope) { e.ID = "" }), message: "has no id"},
+		{name: "bad timestamp", detail: envelope(t, func(e *Envelope) { e.Timestamp = "yesterday" }), message: "invalid timestamp"},
+		{name: "missing timestamp", detail: envelope(t, func(e *Envelope) { e.Timestamp = "" }), message: "invalid timestamp"},
+		{name: "bad payload", detail: envelope(t, func(e *Envelope) { e.Data = json.RawMessage(`{"itemId":1}`) }), message: "invalid ITEM_DELETED payload"},
+		{name: "not an envelope", detail: []byte(`[]`), message: "invalid event envelope"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.detail)
+			switch {
+			case tt.want != nil && !errors.Is(err, tt.want):
+				t.Errorf("Decode returned %v, want %v", err, tt.want)
+			case tt.want == nil && (err == nil || !strings.Contains(err.Error(), tt.message)):
+				t.Errorf("Decode returned %v, want an error containing %q", err, tt.message)
+			}
+		})
+	}
+}
@@ -1,22 +1,130 @@
 package events
 
-import "time"
+const (
+	EventTypeOrderCreated       = "ORDER_CREATED"
+	EventTypeOrderCancelled     = "ORDER_CANCELLED"
+	EventTypeOrderStatusChanged = "ORDER_STATUS_CHANGED"
+	EventTypeItemCreated        = "ITEM_CREATED"
+	EventTypeItemUpdated        = "ITEM_UPDATED"
+	EventTypeItemDeleted        = "ITEM_DELETED"
+
+	EventTypeInventoryUpdated      = "INVENTORY_UPDATED"
+	EventTypeInsufficientInventory = "INSUFFICIENT_INVENTORY"
+	EventTypeInventoryRestored     = "INVENTORY_RESTORED"
+)
+
+const (
+	SourceOrders    = "orders.service"
+	SourceInventory = "inventory.service"
+)
+
+// OrderLine is the stock an order line needs.
+type OrderLine struct {
+	ItemID   string `json:"itemId"`
+	Quantity int    `json:"quantity"`
+}
 
+// OrderCreatedEvent is published once per order with all of its lines.
 type OrderCreatedEvent struct {
-	OrderID   string    `json:"orderId"`
-	ItemID    string    `json:"itemId"`
-	Quantity  int       `json:"quantity"`
-	Timestamp time.Time `json:"timestamp"`
+	OrderID    string      `json:"orderId"`
+	CustomerID string      `json:"customerId"`
+	Items      []OrderLine `json:"items"`
 }
 
+func (OrderCreatedEvent) EventType() string { return EventTypeOrderCreated }
+
 type OrderCancelledEvent struct {
-	OrderID   string    `json:"orderId"`
-	ItemID    string    `json:"itemId"`
-	Quantity  int       `json:"quantity"`
-	Timestamp time.Time `json:"timestamp"`
+	OrderID string      `json:"orderId"`
+	Items   []OrderLine `json:"items"`
 }
 
-const (
-	EventTypeOrderCreated   = "ORDER_CREATED"
-	EventTypeOrderCancelled = "ORDER_CANCELLED"
-)
+func (OrderCancelledEvent) EventType() string { return EventTypeOrderCancelled }
+
+type OrderStatusChangedEvent struct {
+	OrderID        string `json:"orderId"`
+	PreviousStatus string `json:"previousStatus"`
+	Status         string `json:"status"`
+}
+
+func (OrderStatusChangedEvent) EventType() string { return EventTypeOrderStatusChanged }
+
+type ItemCreatedEvent struct {
+	ItemID   string `json:"itemId"`
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+}
+
+func (ItemCreatedEvent) EventType() string { return EventTypeItemCreated }
+
+type ItemUpdatedEvent struct {
+	ItemID           string `json:"itemId"`
+	Name             string `json:"name"`
+	PreviousQuantity int    `json:"previousQuantity"`
+	Quantity         int    `json:"quantity"`
+}
+
+func (ItemUpdatedEvent) EventType() string { return EventTypeItemUpdated }
+
+type ItemDeletedEvent struct {
+	ItemID string `json:"itemId"`
+}
+
+func (ItemDeletedEvent) EventType() string { return EventTypeItemDeleted }
+
+// InventoryUpdatedEvent reports that stock was reserved for every line of
+// an order.
+type InventoryUpdatedEvent struct {
+	OrderID string      `json:"orderId"`
+	Items   []OrderLine `json:"items"`
+}
+
+func (InventoryUpdatedEvent) EventType() string { return EventTypeInventoryUpdated }
+
+// Shortfall is an order line inventory could not cover. Available is the
+// stock on hand when the reservation failed; Missing is set if the item
+// does not exist.
+type Shortfall struct {
+	ItemID    string `json:"itemId"`
+	Requested int    `json:"requested"`
+	Available int    `json:"available"`
+	Missing   bool   `json:"missing,omitempty"`
+}
+
+// InsufficientInventoryEvent reports that an order could not be reserved.
+// No stock was taken for any of its lines.
+type InsufficientInventoryEvent struct {
+	OrderID    string      `json:"orderId"`
+	Shortfalls []Shortfall `json:"shortfalls"`
+}
+
+func (InsufficientInventoryEvent) EventType() string { return EventTypeInsufficientInventory }
+
+// InventoryRestoredEvent reports stock returned for every line of a
+// cancelled order.
+type InventoryRestoredEvent struct {
+	OrderID string      `json:"orderId"`
+	Items   []OrderLine `json:"items"`
+}
+
+func (InventoryRestoredEvent) EventType() string { return EventTypeInventoryRestored }
+
+// definition is the catalog entry for an event type: who publishes it, the
+// payload version producers write and the payload type it decodes into.
+type definition struct {
+	source  string
+	version int
+	payload Payload
+}
+
+var catalog = map[string]definition{
+	EventTypeOrderCreated:       {SourceOrders, 1, OrderCreatedEvent{}},
+	EventTypeOrderCancelled:     {SourceOrders, 1, OrderCancelledEvent{}},
+	EventTypeOrderStatusChanged: {SourceOrders, 1, OrderStatusChangedEvent{}},
+	EventTypeItemCreated:        {SourceInventory, 1, ItemCreatedEvent{}},
+	EventTypeItemUpdated:        {SourceInventory, 1, ItemUpdatedEvent{}},
+	EventTypeItemDeleted:        {SourceInventory, 1, ItemDeletedEvent{}},
+	// Version 2 reports the outcome for the whole order instead of per line.
+	EventTypeInventoryUpdated:      {SourceInventory, 2, InventoryUpdatedEvent{}},
+	EventTypeInsufficientInventory: {SourceInventory, 2, InsufficientInventoryEvent{}},
+	EventTypeInventoryRestored:     {SourceInventory, 2, InventoryRestoredEvent{}},
+}
@@ -0,0 +1,118 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// TTL is how long a key is remembered. The table's TTL attribute removes
+// records some time after they expire; until then they are ignored.
+const TTL = 24 * time.Hour
+
+var (
+	ErrKeyReused   = errors.New("idempotency key was already used with different arguments")
+	ErrKeyNotFound = errors.New("idempotency key not found")
+)
+
+// Key identifies one client request to a mutation. Replays of the request
+// carry the same Value and arguments, hence the same Fingerprint.
+type Key struct {
+	Operation   string
+	Value       string
+	Fingerprint string
+}
+
+// NewKey returns the key for a request, or nil if the client sent no
+// idempotency key. The fingerprint covers the request arguments, so the
+// same key cannot be reused for a different request.
+func NewKey(operation, value string, arguments interface{}) (*Key, error) {
+	if value == "" {
+		return nil, nil
+	}
+	data, err := json.Marshal(arguments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fingerprint request: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return &Key{
+		Operation:   operation,
+		Value:       value,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}, nil
+}
+
+func (k Key) primaryKey() map[string]types.AttributeValue {
+	id := fmt.Sprintf("IDEMPOTENCY#%s#%s", k.Operation, k.Value)
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: id},
+		"SK": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// Put returns the transaction write recording that the request produced
+// resultID. Adding it to the transaction that performs the mutation makes
+// the two atomic: the transaction is cancelled with ConditionalCheckFailed
+// on this write if an unexpired record for the key already exists.
+func (k Key) Put(tableName, resultID string, now time.Time) types.TransactWriteItem {
+	item := k.primaryKey()
+	item["fingerprint"] = &types.AttributeValueMemberS{Value: k.Fingerprint}
+	item["result_id"] = &types.AttributeValueMemberS{Value: resultID}
+	item["expires_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(TTL).Unix(), 10)}
+
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(PK) OR expires_at < :now"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+			},
+		},
+	}
+}
+
+// Lookup returns the result ID recorded for key by an earlier request. It
+// fails with ErrKeyReused if that request had different arguments.
+func Lookup(ctx context.Context, client *dynamodb.Client, tableName string, key Key) (string, error) {
+	result, err := client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(tableName),
+		Key:            key.primaryKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get idempotency record: %v", err)
+	}
+	if result.Item == nil {
+		return "", ErrKeyNotFound
+	}
+
+	fingerprint, _ := result.Item["fingerprint"].(*types.AttributeValueMemberS)
+	if fingerprint == nil || fingerprint.Value != key.Fingerprint {
+		return "", ErrKeyReused
+	}
+	resultID, _ := result.Item["result_id"].(*types.AttributeValueMemberS)
+	if resultID == nil {
+		return "", ErrKeyNotFound
+	}
+	return resultID.Value, nil
+}
+
+// Replayed reports whether a cancelled transaction failed because the
+// idempotency write at index already had a record.
+func Replayed(err error, index int) bool {
+	var cancelled *types.TransactionCanceledException
+	if !errors.As(err, &cancelled) || index >= len(cancelled.CancellationReasons) {
+		return false
+	}
+	return aws.ToString(cancelled.CancellationReasons[index].Code) == "ConditionalCheckFailed"
+}
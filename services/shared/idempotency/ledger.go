@@ -0,0 +1,156 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// LedgerTTL is how long a consumer remembers an event. It covers
+// EventBridge's retry window with room for redrives of failed events.
+const LedgerTTL = 7 * 24 * time.Hour
+
+var ErrAlreadyProcessed = errors.New("event already processed")
+
+// Processed is the ledger entry recording that a consumer handled an
+// event. Outcome is the detail of the event the consumer published in
+// response, if any, so it can be published again on redelivery.
+type Processed struct {
+	Consumer string
+	EventID  string
+	Outcome  []byte
+}
+
+func (p Processed) primaryKey() map[string]types.AttributeValue {
+	id := fmt.Sprintf("PROCESSED#%s#%s", p.Consumer, p.EventID)
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: id},
+		"SK": &types.AttributeValueMemberS{Value: id},
+	}
+}
+
+// Put returns the transaction write adding the entry. It must be the first
+// write of the transaction applying the event; the transaction is then
+// cancelled with ConditionalCheckFailed at index 0 if the event was
+// already processed, which Duplicate detects.
+func (p Processed) Put(tableName string, now time.Time) types.TransactWriteItem {
+	item := p.primaryKey()
+	item["expires_at"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(LedgerTTL).Unix(), 10)}
+	if p.Outcome != nil {
+		item["outcome"] = &types.AttributeValueMemberS{Value: string(p.Outcome)}
+	}
+
+	return types.TransactWriteItem{
+		Put: &types.Put{
+			TableName:           aws.String(tableName),
+			Item:                item,
+			ConditionExpression: aws.String("attribute_not_exists(PK)"),
+		},
+	}
+}
+
+// Duplicate reports whether a transaction starting with a Processed write
+// failed because the event was already processed.
+func Duplicate(err error) bool {
+	return Replayed(err, 0)
+}
+
+// Ledger stores the entries of every consumer. Record adds an entry on its
+// own and returns ErrAlreadyProcessed for a duplicate; Outcome returns the
+// outcome recorded for an entry, or nil.
+type Ledger interface {
+	Record(ctx context.Context, processed Processed) error
+	Outcome(ctx context.Context, processed Processed) ([]byte, error)
+}
+
+// TableLedger is the Ledger kept in a service table, next to the records
+// whose transactions write its entries.
+type TableLedger struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+func NewTableLedger(client *dynamodb.Client, tableName string) *TableLedger {
+	return &TableLedger{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (l *TableLedger) Record(ctx context.Context, processed Processed) error {
+	_, err := l.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{processed.Put(l.tableName, time.Now())},
+	})
+	if Duplicate(err) {
+		return ErrAlreadyProcessed
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record event %s: %v", processed.EventID, err)
+	}
+	return nil
+}
+
+func (l *TableLedger) Outcome(ctx context.Context, processed Processed) ([]byte, error) {
+	result, err := l.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(l.tableName),
+		Key:            processed.primaryKey(),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ledger entry: %v", err)
+	}
+	if outcome, ok := result.Item["outcome"].(*types.AttributeValueMemberS); ok {
+		return []byte(outcome.Value), nil
+	}
+	return nil, nil
+}
+
+// Consumer deduplicates the events one handler consumes.
+type Consumer struct {
+	ledger Ledger
+	name   string
+}
+
+func NewConsumer(ledger Ledger, name string) *Consumer {
+	return &Consumer{
+		ledger: ledger,
+		name:   name,
+	}
+}
+
+// Processed returns the ledger entry for eventID.
+func (c *Consumer) Processed(eventID string, outcome []byte) Processed {
+	return Processed{Consumer: c.name, EventID: eventID, Outcome: outcome}
+}
+
+// Record adds an entry on its own, for events whose handling changes no
+// other state. It returns ErrAlreadyProcessed for a duplicate.
+func (c *Consumer) Record(ctx context.Context, processed Processed) error {
+	return c.ledger.Record(ctx, processed)
+}
+
+// Handle applies an event once. handle performs the state change together
+// with the ledger entry and returns its outcome, or ErrAlreadyProcessed if
+// the entry already existed. The outcome is then passed to publish; for a
+// duplicate the outcome recorded by the first delivery is published again,
+// so a failure between committing and publishing is repaired by the
+// redelivery.
+func (c *Consumer) Handle(ctx context.Context, eventID string, handle func(ctx context.Context) ([]byte, error), publish func(ctx context.Context, outcome []byte) error) error {
+	outcome, err := handle(ctx)
+	if errors.Is(err, ErrAlreadyProcessed) {
+		outcome, err = c.ledger.Outcome(ctx, c.Processed(eventID, nil))
+	}
+	if err != nil {
+		return err
+	}
+	if outcome == nil || publish == nil {
+		return nil
+	}
+	return publish(ctx, outcome)
+}
@@ -0,0 +1,94 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps ledger entries and idempotency keys in process, for
+// repositories that keep their records in memory. Apply and Claim run the
+// caller's change while holding the store, so the change and the entry are
+// atomic like the transactions of the table implementations.
+type Memory struct {
+	mu        sync.Mutex
+	processed map[memoryID][]byte
+	keys      map[memoryID]memoryKey
+}
+
+type memoryID struct {
+	scope string
+	value string
+}
+
+type memoryKey struct {
+	fingerprint string
+	resultID    string
+	expiresAt   time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		processed: make(map[memoryID][]byte),
+		keys:      make(map[memoryID]memoryKey),
+	}
+}
+
+func (m *Memory) Record(ctx context.Context, processed Processed) error {
+	return m.Apply(&processed, func() error { return nil })
+}
+
+func (m *Memory) Outcome(ctx context.Context, processed Processed) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.processed[memoryID{processed.Consumer, processed.EventID}], nil
+}
+
+// Apply runs change and adds processed, unless the event is already in the
+// ledger, in which case it returns ErrAlreadyProcessed without running
+// change. Nothing is added if change fails, and a nil processed just runs
+// change. Entries are kept for good, as the table keeps them for at least
+// LedgerTTL.
+func (m *Memory) Apply(processed *Processed, change func() error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if processed == nil {
+		return change()
+	}
+	id := memoryID{processed.Consumer, processed.EventID}
+	if _, ok := m.processed[id]; ok {
+		return ErrAlreadyProcessed
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	m.processed[id] = processed.Outcome
+	return nil
+}
+
+// Claim runs create for a request and records that it produced resultID.
+// If an unexpired key records an earlier request, create is not run and
+// the earlier result ID is returned instead, or ErrKeyReused if that
+// request had different arguments. A nil key just runs create.
+func (m *Memory) Claim(key *Key, resultID string, create func() error) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if key == nil {
+		return resultID, create()
+	}
+	id := memoryID{key.Operation, key.Value}
+	now := time.Now()
+	if earlier, ok := m.keys[id]; ok && now.Before(earlier.expiresAt) {
+		if earlier.fingerprint != key.Fingerprint {
+			return "", ErrKeyReused
+		}
+		return earlier.resultID, nil
+	}
+	if err := create(); err != nil {
+		return "", err
+	}
+	m.keys[id] = memoryKey{fingerprint: key.Fingerprint, resultID: resultID, expiresAt: now.Add(TTL)}
+	return resultID, nil
+}
@@ -0,0 +1,154 @@
+package pagination
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var ErrInvalidToken = errors.New("invalid nextToken")
+
+// Codec turns a DynamoDB LastEvaluatedKey into an opaque nextToken and back.
+// Tokens are encrypted and authenticated, so clients can neither read key
+// attributes out of them nor forge a starting point, and each token is
+// bound to the query it was issued for.
+type Codec struct {
+	aead cipher.AEAD
+}
+
+func NewCodec(secret []byte) (*Codec, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("cursor secret is empty")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cursor cipher: %v", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cursor cipher: %v", err)
+	}
+	return &Codec{aead: aead}, nil
+}
+
+// LoadCodec creates a Codec keyed by the Secrets Manager secret secretArn.
+func LoadCodec(ctx context.Context, client *secretsmanager.Client, secretArn string) (*Codec, error) {
+	if secretArn == "" {
+		return nil, fmt.Errorf("CURSOR_SECRET_ARN environment variable is not set")
+	}
+	result, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretArn),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load cursor secret: %v", err)
+	}
+	return NewCodec([]byte(aws.ToString(result.SecretString)))
+}
+
+type cursor struct {
+	Scope string              `json:"s"`
+	Key   map[string]keyValue `json:"k"`
+}
+
+type keyValue struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+}
+
+// Encode returns the token resuming a query after key, or nil when key is
+// empty and there are no further pages. scope names the query, e.g. the
+// index it ran against.
+func (c *Codec) Encode(scope string, key map[string]types.AttributeValue) (*string, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	payload := cursor{Scope: scope, Key: make(map[string]keyValue, len(key))}
+	for name, value := range key {
+		switch v := value.(type) {
+		case *types.AttributeValueMemberS:
+			payload.Key[name] = keyValue{S: aws.String(v.Value)}
+		case *types.AttributeValueMemberN:
+			payload.Key[name] = keyValue{N: aws.String(v.Value)}
+		default:
+			return nil, fmt.Errorf("unsupported key attribute %s", name)
+		}
+	}
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal cursor: %v", err)
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to create cursor nonce: %v", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	token := base64.RawURLEncoding.EncodeToString(sealed)
+	return &token, nil
+}
+
+// Decode returns the ExclusiveStartKey for token, or nil for the first page.
+// It fails with ErrInvalidToken if the token was tampered with or was
+// issued for a different scope.
+func (c *Codec) Decode(scope string, token *string) (map[string]types.AttributeValue, error) {
+	if token == nil || *token == "" {
+		return nil, nil
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(*token)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, ErrInvalidToken
+	}
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var payload cursor
+	if err := json.Unmarshal(plaintext, &payload); err != nil || payload.Scope != scope {
+		return nil, ErrInvalidToken
+	}
+
+	key := make(map[string]types.AttributeValue, len(payload.Key))
+	for name, value := range payload.Key {
+		switch {
+		case value.S != nil:
+			key[name] = &types.AttributeValueMemberS{Value: *value.S}
+		case value.N != nil:
+			key[name] = &types.AttributeValueMemberN{Value: *value.N}
+		default:
+			return nil, ErrInvalidToken
+		}
+	}
+	return key, nil
+}
+
+// Limit clamps a requested page size to [1, MaxLimit], defaulting to
+// DefaultLimit.
+func Limit(requested *int) int32 {
+	if requested == nil || *requested <= 0 {
+		return DefaultLimit
+	}
+	if *requested > MaxLimit {
+		return MaxLimit
+	}
+	return int32(*requested)
+}
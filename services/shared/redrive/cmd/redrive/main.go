@@ -0,0 +1,226 @@
+// Command redrive inspects, edits and resubmits the messages in the
+// erp-*-dlq queues.
+//
+//	redrive inspect -queue erp-inventory-events-dlq
+//	redrive export -queue erp-inventory-events-dlq -file failed.jsonl
+//	redrive resubmit -queue erp-inventory-events-dlq -file failed.jsonl
+//	redrive resubmit -queue erp-inventory-stream-dlq
+//
+// export writes one message per line and hides the messages for
+// -visibility; edit the payloads, then resubmit the file before the
+// messages reappear. Without -file, resubmit takes the messages straight
+// off the queue. A resubmitted message is deleted once its target accepts
+// it; failed messages stay on the queue, as do messages that cannot be
+// read, which every command reports.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"serp/services/shared/redrive"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// maxLineSize bounds one exported message; stream batches can be large.
+const maxLineSize = 16 * 1024 * 1024
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		log.Fatal("usage: redrive inspect|export|resubmit [flags]")
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatalf("failed to load AWS config: %v", err)
+	}
+	r := redrive.New(cfg)
+
+	switch os.Args[1] {
+	case "inspect":
+		err = inspect(ctx, r, os.Args[2:])
+	case "export":
+		err = export(ctx, r, os.Args[2:])
+	case "resubmit":
+		err = resubmit(ctx, r, os.Args[2:])
+	default:
+		err = fmt.Errorf("unknown command %q", os.Args[1])
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+type options struct {
+	flags      *flag.FlagSet
+	queue      string
+	file       string
+	max        int
+	visibility time.Duration
+}
+
+func newOptions(name string, visibility time.Duration, withFile bool) *options {
+	o := &options{flags: flag.NewFlagSet(name, flag.ContinueOnError)}
+	o.flags.StringVar(&o.queue, "queue", "", "queue name or URL")
+	o.flags.IntVar(&o.max, "max", 100, "maximum number of messages")
+	o.flags.DurationVar(&o.visibility, "visibility", visibility, "how long received messages are hidden")
+	if withFile {
+		o.flags.StringVar(&o.file, "file", "", "JSONL file of messages")
+	}
+	return o
+}
+
+func (o *options) parse(args []string) error {
+	if err := o.flags.Parse(args); err != nil {
+		return err
+	}
+	if o.queue == "" {
+		return fmt.Errorf("-queue is required")
+	}
+	return nil
+}
+
+func inspect(ctx context.Context, r *redrive.Redriver, args []string) error {
+	o := newOptions("inspect", 30*time.Second, false)
+	payload := o.flags.Bool("payload", false, "print each payload")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	queueURL, err := r.QueueURL(ctx, o.queue)
+	if err != nil {
+		return err
+	}
+	messages, unreadable, err := r.Receive(ctx, queueURL, o.max, o.visibility)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		fmt.Printf("%s %s %s\n  %s\n", message.MessageID, message.Kind, message.Target, message.Error)
+		if *payload {
+			fmt.Printf("  %s\n", message.Payload)
+		}
+	}
+	for _, message := range unreadable {
+		fmt.Printf("%s unreadable\n  %v\n", message.MessageID, message.Err)
+		if *payload {
+			fmt.Printf("  %s\n", message.Body)
+		}
+	}
+	fmt.Printf("%d messages, %d unreadable\n", len(messages), len(unreadable))
+	return nil
+}
+
+func export(ctx context.Context, r *redrive.Redriver, args []string) error {
+	o := newOptions("export", 15*time.Minute, true)
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if o.file == "" {
+		return fmt.Errorf("-file is required")
+	}
+	queueURL, err := r.QueueURL(ctx, o.queue)
+	if err != nil {
+		return err
+	}
+	messages, unreadable, err := r.Receive(ctx, queueURL, o.max, o.visibility)
+	if err != nil {
+		return err
+	}
+	report(unreadable)
+
+	f, err := os.Create(o.file)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", o.file, err)
+	}
+	defer f.Close()
+	encoder := json.NewEncoder(f)
+	for _, message := range messages {
+		if err := encoder.Encode(message); err != nil {
+			return fmt.Errorf("failed to write %s: %v", o.file, err)
+		}
+	}
+	fmt.Printf("exported %d messages, hidden until %s\n", len(messages), time.Now().Add(o.visibility).Format(time.Kitchen))
+	return nil
+}
+
+func resubmit(ctx context.Context, r *redrive.Redriver, args []string) error {
+	o := newOptions("resubmit", 5*time.Minute, true)
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	queueURL, err := r.QueueURL(ctx, o.queue)
+	if err != nil {
+		return err
+	}
+
+	var messages []redrive.Message
+	var unreadable []redrive.Unreadable
+	if o.file != "" {
+		messages, err = readMessages(o.file)
+	} else {
+		messages, unreadable, err = r.Receive(ctx, queueURL, o.max, o.visibility)
+	}
+	if err != nil {
+		return err
+	}
+	report(unreadable)
+
+	failed := len(unreadable)
+	for _, message := range messages {
+		if err := r.Resubmit(ctx, queueURL, message); err != nil {
+			log.Print(err)
+			failed++
+		}
+	}
+	fmt.Printf("resubmitted %d of %d messages\n", len(messages)+len(unreadable)-failed, len(messages)+len(unreadable))
+	if failed > 0 {
+		return fmt.Errorf("%d messages failed", failed)
+	}
+	return nil
+}
+
+// report logs the messages Receive could not read, with their bodies.
+// They stay on the queue.
+func report(unreadable []redrive.Unreadable) {
+	for _, message := range unreadable {
+		log.Printf("%v\n  %s", message, message.Body)
+	}
+}
+
+func readMessages(file string) ([]redrive.Message, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", file, err)
+	}
+	defer f.Close()
+
+	var messages []redrive.Message
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var message redrive.Message
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			return nil, fmt.Errorf("line %d: invalid message: %v", line, err)
+		}
+		messages = append(messages, message)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", file, err)
+	}
+	return messages, nil
+}
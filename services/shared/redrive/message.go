@@ -0,0 +1,113 @@
+package redrive
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Kind is the asynchronous path a dead-lettered message failed on, which
+// decides how it is resubmitted.
+type Kind string
+
+const (
+	// KindInvocation is an asynchronous invocation that kept failing; its
+	// payload is the original invocation payload.
+	KindInvocation Kind = "invocation"
+	// KindRuleTarget is an event EventBridge could not deliver to a rule
+	// target; its payload is the event.
+	KindRuleTarget Kind = "rule-target"
+	// KindStream is a stream batch that kept failing; its payload is the
+	// DynamoDB stream event read back from the stream.
+	KindStream Kind = "stream"
+)
+
+// Message is a dead-lettered message in the form it is inspected, edited
+// and resubmitted in. Target is the ARN of the function or state machine
+// the payload is resubmitted to.
+type Message struct {
+	MessageID     string          `json:"messageId"`
+	ReceiptHandle string          `json:"receiptHandle"`
+	Kind          Kind            `json:"kind"`
+	Target        string          `json:"target"`
+	Error         string          `json:"error,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+
+	batch *streamBatch
+}
+
+// destinationRecord is the body Lambda sends to an on-failure destination
+// for asynchronous invocations and stream batches.
+type destinationRecord struct {
+	RequestContext struct {
+		FunctionArn string `json:"functionArn"`
+		Condition   string `json:"condition"`
+	} `json:"requestContext"`
+	RequestPayload  json.RawMessage `json:"requestPayload"`
+	ResponsePayload struct {
+		ErrorMessage string `json:"errorMessage"`
+		ErrorType    string `json:"errorType"`
+	} `json:"responsePayload"`
+	StreamBatch *streamBatch `json:"DDBStreamBatchInfo"`
+}
+
+type streamBatch struct {
+	ShardID             string `json:"shardId"`
+	StartSequenceNumber string `json:"startSequenceNumber"`
+	EndSequenceNumber   string `json:"endSequenceNumber"`
+	BatchSize           int    `json:"batchSize"`
+	StreamArn           string `json:"streamArn"`
+}
+
+// parse recognises the three bodies the DLQs receive. A stream batch is
+// returned without its payload, which has to be read from the stream.
+func parse(message types.Message) (Message, error) {
+	result := Message{
+		MessageID:     aws.ToString(message.MessageId),
+		ReceiptHandle: aws.ToString(message.ReceiptHandle),
+	}
+	body := []byte(aws.ToString(message.Body))
+
+	// EventBridge describes the failed delivery in message attributes.
+	if target, ok := message.MessageAttributes["TARGET_ARN"]; ok {
+		result.Kind = KindRuleTarget
+		result.Target = aws.ToString(target.StringValue)
+		result.Error = attribute(message, "ERROR_CODE") + ": " + attribute(message, "ERROR_MESSAGE")
+		result.Payload = body
+		return result, nil
+	}
+
+	var record destinationRecord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return Message{}, fmt.Errorf("unrecognised body: %v", err)
+	}
+	if record.RequestContext.FunctionArn == "" {
+		return Message{}, fmt.Errorf("unrecognised body")
+	}
+	result.Target = record.RequestContext.FunctionArn
+	result.Error = record.RequestContext.Condition
+
+	switch {
+	case record.StreamBatch != nil:
+		result.Kind = KindStream
+		result.batch = record.StreamBatch
+	case record.RequestPayload != nil:
+		result.Kind = KindInvocation
+		result.Payload = record.RequestPayload
+		if record.ResponsePayload.ErrorType != "" {
+			result.Error = record.ResponsePayload.ErrorType + ": " + record.ResponsePayload.ErrorMessage
+		}
+	default:
+		return Message{}, fmt.Errorf("no payload or stream batch")
+	}
+	return result, nil
+}
+
+func attribute(message types.Message, name string) string {
+	if value, ok := message.MessageAttributes[name]; ok {
+		return aws.ToString(value.StringValue)
+	}
+	return ""
+}
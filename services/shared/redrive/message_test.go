@@ -0,0 +1,152 @@
+package redrive
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const ruleTargetBody = `{"version":"0","id":"7f1c3a52-4d1e-4c9b-9a8e-2f0d5c3e1b6a","detail-type":"ORDER_CREATED","source":"orders.service","account":"123456789012","time":"2024-05-01T10:00:00Z","region":"us-east-1","resources":[],"detail":{"id":"event-1","type":"ORDER_CREATED","version":1}}`
+
+const invocationBody = `{
+  "version": "1.0",
+  "timestamp": "2024-05-01T10:00:05.123Z",
+  "requestContext": {
+    "requestId": "c8a1d0f2-5b3e-4e7a-9d41-0f6b2a7c9e13",
+    "functionArn": "arn:aws:lambda:us-east-1:123456789012:function:erp-inventory-saga:$LATEST",
+    "condition": "RetriesExhausted",
+    "approximateInvokeCount": 3
+  },
+  "requestPayload": {"action": "reserve", "eventId": "event-1", "orderId": "order-1"},
+  "responseContext": {"statusCode": 200, "executedVersion": "$LATEST", "functionError": "Unhandled"},
+  "responsePayload": {"errorMessage": "failed to reserve stock: throttled", "errorType": "errorString"}
+}`
+
+const streamBody = `{
+  "requestContext": {
+    "requestId": "2f9e7b3a-1c4d-4f8e-a6b5-9d0c3e2f1a47",
+    "functionArn": "arn:aws:lambda:us-east-1:123456789012:function:erp-orders-stream",
+    "condition": "RetryAttemptsExhausted",
+    "approximateInvokeCount": 1
+  },
+  "responseContext": {"statusCode": 0, "executedVersion": "$LATEST", "functionError": "Unhandled"},
+  "version": "1.0",
+  "timestamp": "2024-05-01T10:00:09.456Z",
+  "DDBStreamBatchInfo": {
+    "shardId": "shardId-00000001714550000000-6b1c2d3e",
+    "startSequenceNumber": "100000000000000000001",
+    "endSequenceNumber": "100000000000000000003",
+    "approximateArrivalOfFirstRecord": "2024-05-01T10:00:00Z",
+    "approximateArrivalOfLastRecord": "2024-05-01T10:00:01Z",
+    "batchSize": 3,
+    "streamArn": "arn:aws:dynamodb:us-east-1:123456789012:table/erp-orders/stream/2024-05-01T00:00:00.000"
+  }
+}`
+
+func sqsMessage(body string, attributes map[string]string) types.Message {
+	message := types.Message{
+		MessageId:     aws.String("message-1"),
+		ReceiptHandle: aws.String("receipt-1"),
+		Body:          aws.String(body),
+	}
+	if attributes != nil {
+		message.MessageAttributes = make(map[string]types.MessageAttributeValue, len(attributes))
+		for name, value := range attributes {
+			message.MessageAttributes[name] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(value)}
+		}
+	}
+	return message
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		message types.Message
+		want    Message
+	}{
+		{
+			name: "rule target",
+			message: sqsMessage(ruleTargetBody, map[string]string{
+				"RULE_ARN":                  "arn:aws:events:us-east-1:123456789012:rule/erp-event-bus/inventory-order-created",
+				"TARGET_ARN":                "arn:aws:states:us-east-1:123456789012:stateMachine:erp-fulfilment",
+				"ERROR_CODE":                "SDK_CLIENT_ERROR",
+				"ERROR_MESSAGE":             "Unable to invoke the target",
+				"EXHAUSTED_RETRY_CONDITION": "MaximumRetryAttempts",
+				"RETRY_ATTEMPTS":            "185",
+			}),
+			want: Message{
+				MessageID:     "message-1",
+				ReceiptHandle: "receipt-1",
+				Kind:          KindRuleTarget,
+				Target:        "arn:aws:states:us-east-1:123456789012:stateMachine:erp-fulfilment",
+				Error:         "SDK_CLIENT_ERROR: Unable to invoke the target",
+				Payload:       json.RawMessage(ruleTargetBody),
+			},
+		},
+		{
+			name:    "invocation",
+			message: sqsMessage(invocationBody, nil),
+			want: Message{
+				MessageID:     "message-1",
+				ReceiptHandle: "receipt-1",
+				Kind:          KindInvocation,
+				Target:        "arn:aws:lambda:us-east-1:123456789012:function:erp-inventory-saga:$LATEST",
+				Error:         "errorString: failed to reserve stock: throttled",
+				Payload:       json.RawMessage(`{"action": "reserve", "eventId": "event-1", "orderId": "order-1"}`),
+			},
+		},
+		{
+			name:    "stream batch",
+			message: sqsMessage(streamBody, nil),
+			want: Message{
+				MessageID:     "message-1",
+				ReceiptHandle: "receipt-1",
+				Kind:          KindStream,
+				Target:        "arn:aws:lambda:us-east-1:123456789012:function:erp-orders-stream",
+				Error:         "RetryAttemptsExhausted",
+				batch: &streamBatch{
+					ShardID:             "shardId-00000001714550000000-6b1c2d3e",
+					StartSequenceNumber: "100000000000000000001",
+					EndSequenceNumber:   "100000000000000000003",
+					BatchSize:           3,
+					StreamArn:           "arn:aws:dynamodb:us-east-1:123456789012:table/erp-orders/stream/2024-05-01T00:00:00.000",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parse(tt.message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsed %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"not JSON", "order-1 failed", "unrecognised body: "},
+		{"event without target attributes", ruleTargetBody, "unrecognised body"},
+		{"destination record without payload", `{"requestContext":{"functionArn":"arn:aws:lambda:us-east-1:123456789012:function:erp-inventory-saga","condition":"RetriesExhausted"}}`, "no payload or stream batch"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse(sqsMessage(tt.body, nil))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parse returned %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
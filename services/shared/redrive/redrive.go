@@ -0,0 +1,178 @@
+package redrive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdatypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/aws/aws-sdk-go-v2/service/sfn"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// Redriver reads dead-lettered messages from the erp-*-dlq queues and
+// resubmits them to the function or state machine they failed on.
+type Redriver struct {
+	sqs     *sqs.Client
+	lambda  *lambda.Client
+	sfn     *sfn.Client
+	streams *dynamodbstreams.Client
+}
+
+func New(cfg aws.Config) *Redriver {
+	return &Redriver{
+		sqs:     sqs.NewFromConfig(cfg),
+		lambda:  lambda.NewFromConfig(cfg),
+		sfn:     sfn.NewFromConfig(cfg),
+		streams: dynamodbstreams.NewFromConfig(cfg),
+	}
+}
+
+// QueueURL resolves a queue name such as erp-inventory-events-dlq. URLs
+// are returned as they are.
+func (r *Redriver) QueueURL(ctx context.Context, queue string) (string, error) {
+	if strings.HasPrefix(queue, "https://") {
+		return queue, nil
+	}
+	result, err := r.sqs.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return "", fmt.Errorf("failed to find queue %s: %v", queue, err)
+	}
+	return aws.ToString(result.QueueUrl), nil
+}
+
+// Unreadable is a received message that could not be turned into a
+// Message, such as a stream batch whose records have expired. It stays on
+// the queue and reappears once visibility expires.
+type Unreadable struct {
+	MessageID string
+	Body      string
+	Err       error
+}
+
+func (u Unreadable) Error() string {
+	return fmt.Sprintf("message %s: %v", u.MessageID, u.Err)
+}
+
+// Receive takes up to max messages off the queue, hiding them from other
+// readers for visibility, and returns them with their payloads. Messages
+// that fail to parse are returned apart as unreadable, so one bad message
+// does not hold up the others. Messages that are not deleted by Resubmit
+// reappear once visibility expires.
+func (r *Redriver) Receive(ctx context.Context, queueURL string, max int, visibility time.Duration) ([]Message, []Unreadable, error) {
+	var messages []Message
+	var unreadable []Unreadable
+	for len(messages)+len(unreadable) < max {
+		result, err := r.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              aws.String(queueURL),
+			MaxNumberOfMessages:   int32(min(10, max-len(messages)-len(unreadable))),
+			VisibilityTimeout:     int32(visibility.Seconds()),
+			WaitTimeSeconds:       1,
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to receive messages: %v", err)
+		}
+		if len(result.Messages) == 0 {
+			break
+		}
+		for _, received := range result.Messages {
+			message, err := r.read(ctx, received)
+			if err != nil {
+				unreadable = append(unreadable, Unreadable{
+					MessageID: aws.ToString(received.MessageId),
+					Body:      aws.ToString(received.Body),
+					Err:       err,
+				})
+				continue
+			}
+			messages = append(messages, message)
+		}
+	}
+	return messages, unreadable, nil
+}
+
+// read parses a received message and reads the payload of a stream batch
+// back from the stream.
+func (r *Redriver) read(ctx context.Context, received sqstypes.Message) (Message, error) {
+	message, err := parse(received)
+	if err != nil {
+		return Message{}, err
+	}
+	if message.Kind == KindStream {
+		event, err := readBatch(ctx, r.streams, message.batch)
+		if err != nil {
+			return Message{}, err
+		}
+		if message.Payload, err = json.Marshal(event); err != nil {
+			return Message{}, err
+		}
+	}
+	return message, nil
+}
+
+// Resubmit sends the message payload, possibly edited, to its target and
+// deletes the message from the queue once the target accepted it.
+func (r *Redriver) Resubmit(ctx context.Context, queueURL string, message Message) error {
+	var err error
+	switch {
+	case message.Kind == KindRuleTarget && strings.Contains(message.Target, ":states:"):
+		_, err = r.sfn.StartExecution(ctx, &sfn.StartExecutionInput{
+			StateMachineArn: aws.String(message.Target),
+			Input:           aws.String(string(message.Payload)),
+		})
+		if err != nil {
+			err = fmt.Errorf("failed to start execution: %v", err)
+		}
+	case message.Kind == KindRuleTarget, message.Kind == KindInvocation, message.Kind == KindStream:
+		err = r.invoke(ctx, message)
+	default:
+		err = fmt.Errorf("unknown kind %q", message.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("message %s: %v", message.MessageID, err)
+	}
+
+	_, err = r.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(queueURL),
+		ReceiptHandle: aws.String(message.ReceiptHandle),
+	})
+	if err != nil {
+		return fmt.Errorf("message %s was resubmitted but not deleted: %v", message.MessageID, err)
+	}
+	return nil
+}
+
+// invoke runs the target function synchronously, so a failure is reported
+// here instead of going back to the queue.
+func (r *Redriver) invoke(ctx context.Context, message Message) error {
+	result, err := r.lambda.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName:   aws.String(message.Target),
+		InvocationType: lambdatypes.InvocationTypeRequestResponse,
+		Payload:        message.Payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to invoke %s: %v", message.Target, err)
+	}
+	if result.FunctionError != nil {
+		return fmt.Errorf("%s failed: %s", message.Target, result.Payload)
+	}
+
+	if message.Kind == KindStream {
+		var response events.DynamoDBEventResponse
+		if err := json.Unmarshal(result.Payload, &response); err != nil {
+			return fmt.Errorf("invalid response from %s: %v", message.Target, err)
+		}
+		if len(response.BatchItemFailures) > 0 {
+			return fmt.Errorf("%s failed on record %s", message.Target, response.BatchItemFailures[0].ItemIdentifier)
+		}
+	}
+	return nil
+}
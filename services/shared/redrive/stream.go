@@ -0,0 +1,177 @@
+package redrive
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// maxEmptyPages bounds the empty pages readBatch follows. A shard may
+// return empty pages before the records it holds, and an open shard
+// returns them indefinitely once it is read to the end.
+const maxEmptyPages = 5
+
+// streamReader is the part of *dynamodbstreams.Client readBatch uses.
+type streamReader interface {
+	GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+// readBatch reads the records of a failed stream batch back from the
+// stream. Stream records are kept for 24 hours, so a batch must be
+// redriven within a day of failing.
+func readBatch(ctx context.Context, client streamReader, batch *streamBatch) (events.DynamoDBEvent, error) {
+	iterator, err := client.GetShardIterator(ctx, &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(batch.StreamArn),
+		ShardId:           aws.String(batch.ShardID),
+		ShardIteratorType: types.ShardIteratorTypeAtSequenceNumber,
+		SequenceNumber:    aws.String(batch.StartSequenceNumber),
+	})
+	if err != nil {
+		return events.DynamoDBEvent{}, fmt.Errorf("failed to read shard %s: %v", batch.ShardID, err)
+	}
+
+	var event events.DynamoDBEvent
+	emptyPages := 0
+	next := iterator.ShardIterator
+	for next != nil && emptyPages < maxEmptyPages {
+		result, err := client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{ShardIterator: next})
+		if err != nil {
+			return events.DynamoDBEvent{}, fmt.Errorf("failed to read records of shard %s: %v", batch.ShardID, err)
+		}
+		if len(result.Records) == 0 {
+			emptyPages++
+		}
+		for _, record := range result.Records {
+			sequence := aws.ToString(record.Dynamodb.SequenceNumber)
+			if compareSequence(sequence, batch.EndSequenceNumber) > 0 {
+				return event, nil
+			}
+			converted, err := convertRecord(record, batch.StreamArn)
+			if err != nil {
+				return events.DynamoDBEvent{}, err
+			}
+			event.Records = append(event.Records, converted)
+			if compareSequence(sequence, batch.EndSequenceNumber) == 0 {
+				return event, nil
+			}
+		}
+		next = result.NextShardIterator
+	}
+	if len(event.Records) == 0 {
+		return events.DynamoDBEvent{}, fmt.Errorf("records %s to %s are no longer in the stream", batch.StartSequenceNumber, batch.EndSequenceNumber)
+	}
+	// A closed shard ends before the batch only if its last records
+	// expired; an open one may still hold them after more empty pages.
+	last := event.Records[len(event.Records)-1].Change.SequenceNumber
+	return events.DynamoDBEvent{}, fmt.Errorf("records after %s up to %s could not be read from the stream", last, batch.EndSequenceNumber)
+}
+
+// compareSequence compares stream sequence numbers, which are decimal
+// numbers too large for an int64.
+func compareSequence(a, b string) int {
+	x, _ := new(big.Int).SetString(a, 10)
+	y, _ := new(big.Int).SetString(b, 10)
+	if x == nil || y == nil {
+		return 0
+	}
+	return x.Cmp(y)
+}
+
+// convertRecord turns a record read from the stream into the form Lambda
+// delivers it in.
+func convertRecord(record types.Record, streamArn string) (events.DynamoDBEventRecord, error) {
+	change := record.Dynamodb
+	keys, err := convertImage(change.Keys)
+	if err != nil {
+		return events.DynamoDBEventRecord{}, err
+	}
+	oldImage, err := convertImage(change.OldImage)
+	if err != nil {
+		return events.DynamoDBEventRecord{}, err
+	}
+	newImage, err := convertImage(change.NewImage)
+	if err != nil {
+		return events.DynamoDBEventRecord{}, err
+	}
+
+	converted := events.DynamoDBEventRecord{
+		AWSRegion:      aws.ToString(record.AwsRegion),
+		EventID:        aws.ToString(record.EventID),
+		EventName:      string(record.EventName),
+		EventSource:    aws.ToString(record.EventSource),
+		EventVersion:   aws.ToString(record.EventVersion),
+		EventSourceArn: streamArn,
+		Change: events.DynamoDBStreamRecord{
+			Keys:           keys,
+			OldImage:       oldImage,
+			NewImage:       newImage,
+			SequenceNumber: aws.ToString(change.SequenceNumber),
+			SizeBytes:      aws.ToInt64(change.SizeBytes),
+			StreamViewType: string(change.StreamViewType),
+		},
+	}
+	if change.ApproximateCreationDateTime != nil {
+		converted.Change.ApproximateCreationDateTime = events.SecondsEpochTime{Time: *change.ApproximateCreationDateTime}
+	}
+	return converted, nil
+}
+
+func convertImage(image map[string]types.AttributeValue) (map[string]events.DynamoDBAttributeValue, error) {
+	if image == nil {
+		return nil, nil
+	}
+	result := make(map[string]events.DynamoDBAttributeValue, len(image))
+	for name, value := range image {
+		av, err := convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %v", name, err)
+		}
+		result[name] = av
+	}
+	return result, nil
+}
+
+func convert(value types.AttributeValue) (events.DynamoDBAttributeValue, error) {
+	switch v := value.(type) {
+	case *types.AttributeValueMemberS:
+		return events.NewStringAttribute(v.Value), nil
+	case *types.AttributeValueMemberN:
+		return events.NewNumberAttribute(v.Value), nil
+	case *types.AttributeValueMemberB:
+		return events.NewBinaryAttribute(v.Value), nil
+	case *types.AttributeValueMemberBOOL:
+		return events.NewBooleanAttribute(v.Value), nil
+	case *types.AttributeValueMemberNULL:
+		return events.NewNullAttribute(), nil
+	case *types.AttributeValueMemberSS:
+		return events.NewStringSetAttribute(v.Value), nil
+	case *types.AttributeValueMemberNS:
+		return events.NewNumberSetAttribute(v.Value), nil
+	case *types.AttributeValueMemberBS:
+		return events.NewBinarySetAttribute(v.Value), nil
+	case *types.AttributeValueMemberL:
+		list := make([]events.DynamoDBAttributeValue, len(v.Value))
+		for i, element := range v.Value {
+			av, err := convert(element)
+			if err != nil {
+				return events.DynamoDBAttributeValue{}, err
+			}
+			list[i] = av
+		}
+		return events.NewListAttribute(list), nil
+	case *types.AttributeValueMemberM:
+		m, err := convertImage(v.Value)
+		if err != nil {
+			return events.DynamoDBAttributeValue{}, err
+		}
+		return events.NewMapAttribute(m), nil
+	default:
+		return events.DynamoDBAttributeValue{}, fmt.Errorf("unsupported attribute value %T", value)
+	}
+}
@@ -0,0 +1,82 @@
+package redrive
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+)
+
+// shard serves pages of records, one per GetRecords call, and then empty
+// pages as an open shard does.
+type shard struct {
+	pages [][]string
+	reads int
+}
+
+func (s *shard) GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String("0")}, nil
+}
+
+func (s *shard) GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	page := s.reads
+	s.reads++
+	output := &dynamodbstreams.GetRecordsOutput{NextShardIterator: aws.String(fmt.Sprint(s.reads))}
+	if page >= len(s.pages) {
+		return output, nil
+	}
+	for _, sequence := range s.pages[page] {
+		output.Records = append(output.Records, types.Record{
+			EventID:   aws.String("event-" + sequence),
+			EventName: types.OperationTypeInsert,
+			Dynamodb:  &types.StreamRecord{SequenceNumber: aws.String(sequence)},
+		})
+	}
+	return output, nil
+}
+
+func TestReadBatch(t *testing.T) {
+	batch := &streamBatch{StartSequenceNumber: "200", EndSequenceNumber: "400"}
+	tests := []struct {
+		name  string
+		pages [][]string
+		want  []string
+		err   string
+	}{
+		{"one page", [][]string{{"200", "300", "400", "500"}}, []string{"200", "300", "400"}, ""},
+		{"ends on the last record", [][]string{{"200", "300"}, {"400"}}, []string{"200", "300", "400"}, ""},
+		{"empty pages before the records", [][]string{{}, {}, {"200", "300"}, {}, {"400", "500"}}, []string{"200", "300", "400"}, ""},
+		{"records expired", nil, nil, "no longer in the stream"},
+		{"records missing after some", [][]string{{"200", "300"}}, nil, "records after 300 up to 400"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := &shard{pages: tt.pages}
+			event, err := readBatch(context.Background(), reader, batch)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("readBatch returned %v, want an error containing %q", err, tt.err)
+				}
+				if reader.reads > len(tt.pages)+maxEmptyPages {
+					t.Errorf("read %d pages, want at most %d", reader.reads, len(tt.pages)+maxEmptyPages)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, record := range event.Records {
+				got = append(got, record.Change.SequenceNumber)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("read records %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
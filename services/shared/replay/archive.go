@@ -0,0 +1,149 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+// Names of the archive and bus created by the shared stack.
+const (
+	ArchiveName  = "erp-event-archive"
+	EventBusName = "erp-event-bus"
+)
+
+// Request describes a replay from an archive back onto the bus it
+// archives.
+type Request struct {
+	Name    string
+	Archive string
+	Window  Window
+	Filter  Filter
+}
+
+// Start starts replaying the archived events in the request window onto
+// the archived bus. The archive defaults to ArchiveName.
+// EventBridge replays to rules rather than filtering events, so a filter
+// limits the replay to the rules on the bus that can match it; those rules
+// receive every event they match in the window. Consumers deduplicate by
+// event ID, so events they already processed are no-ops.
+func Start(ctx context.Context, client *eventbridge.Client, req Request) (*eventbridge.StartReplayOutput, error) {
+	if req.Window.Start.IsZero() || req.Window.End.IsZero() {
+		return nil, fmt.Errorf("a replay needs both a start and an end time")
+	}
+	if !req.Window.Start.Before(req.Window.End) {
+		return nil, fmt.Errorf("replay start %s is not before end %s", req.Window.Start, req.Window.End)
+	}
+
+	archiveName := req.Archive
+	if archiveName == "" {
+		archiveName = ArchiveName
+	}
+	archive, err := client.DescribeArchive(ctx, &eventbridge.DescribeArchiveInput{
+		ArchiveName: aws.String(archiveName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe archive %s: %v", archiveName, err)
+	}
+
+	eventBusArn := aws.ToString(archive.EventSourceArn)
+	destination := &types.ReplayDestination{Arn: aws.String(eventBusArn)}
+	if !req.Filter.empty() {
+		rules, err := matchingRules(ctx, client, eventBusArn, req.Filter)
+		if err != nil {
+			return nil, err
+		}
+		if len(rules) == 0 {
+			return nil, fmt.Errorf("no rule on %s matches the filter", eventBusArn)
+		}
+		destination.FilterArns = rules
+	}
+
+	name := req.Name
+	if name == "" {
+		name = "erp-replay-" + time.Now().UTC().Format("20060102T150405")
+	}
+
+	result, err := client.StartReplay(ctx, &eventbridge.StartReplayInput{
+		ReplayName:     aws.String(name),
+		EventSourceArn: archive.ArchiveArn,
+		EventStartTime: aws.Time(req.Window.Start),
+		EventEndTime:   aws.Time(req.Window.End),
+		Destination:    destination,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to start replay %s: %v", name, err)
+	}
+	return result, nil
+}
+
+// Describe returns the state of a replay.
+func Describe(ctx context.Context, client *eventbridge.Client, name string) (*eventbridge.DescribeReplayOutput, error) {
+	result, err := client.DescribeReplay(ctx, &eventbridge.DescribeReplayInput{
+		ReplayName: aws.String(name),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe replay %s: %v", name, err)
+	}
+	return result, nil
+}
+
+// matchingRules returns the ARNs of the rules on the bus whose pattern can
+// match an event selected by filter. Rules managed by AWS services, such
+// as the archive's own rule, are skipped.
+func matchingRules(ctx context.Context, client *eventbridge.Client, eventBusArn string, filter Filter) ([]string, error) {
+	var arns []string
+	input := &eventbridge.ListRulesInput{EventBusName: aws.String(eventBusArn)}
+	for {
+		result, err := client.ListRules(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list rules: %v", err)
+		}
+		for _, rule := range result.Rules {
+			if rule.ManagedBy != nil || rule.EventPattern == nil {
+				continue
+			}
+			matches, err := patternMatches(aws.ToString(rule.EventPattern), filter)
+			if err != nil {
+				return nil, fmt.Errorf("rule %s: %v", aws.ToString(rule.Name), err)
+			}
+			if matches {
+				arns = append(arns, aws.ToString(rule.Arn))
+			}
+		}
+		if result.NextToken == nil {
+			return arns, nil
+		}
+		input.NextToken = result.NextToken
+	}
+}
+
+// patternMatches reports whether an event pattern can match an event the
+// filter selects. Only plain values in the pattern's source and
+// detail-type are compared; any other matcher is assumed to match.
+func patternMatches(pattern string, filter Filter) (bool, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(pattern), &fields); err != nil {
+		return false, fmt.Errorf("invalid event pattern: %v", err)
+	}
+	return overlaps(fields["source"], filter.Sources) && overlaps(fields["detail-type"], filter.DetailTypes), nil
+}
+
+func overlaps(field interface{}, values []string) bool {
+	matchers, ok := field.([]interface{})
+	if !ok || len(values) == 0 {
+		return true
+	}
+	for _, matcher := range matchers {
+		value, ok := matcher.(string)
+		if !ok || matchesAny(values, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,46 @@
+package replay
+
+import "testing"
+
+func TestPatternMatches(t *testing.T) {
+	orders := Filter{Sources: []string{"orders.service"}}
+	created := Filter{DetailTypes: []string{"ORDER_CREATED"}}
+	tests := []struct {
+		name    string
+		pattern string
+		filter  Filter
+		want    bool
+		wantErr bool
+	}{
+		{name: "empty filter", pattern: `{"source":["inventory.service"]}`, filter: Filter{}, want: true},
+		{name: "no source or detail-type", pattern: `{"detail":{"orderId":[{"exists":true}]}}`, filter: orders, want: true},
+		{name: "source listed", pattern: `{"source":["inventory.service","orders.service"]}`, filter: orders, want: true},
+		{name: "source not listed", pattern: `{"source":["inventory.service"]}`, filter: orders, want: false},
+		{name: "no source", pattern: `{"detail-type":["ITEM_CREATED"]}`, filter: orders, want: true},
+		{name: "detail-type listed", pattern: `{"source":["orders.service"],"detail-type":["ORDER_CREATED"]}`, filter: created, want: true},
+		{name: "detail-type not listed", pattern: `{"source":["orders.service"],"detail-type":["ORDER_CANCELLED"]}`, filter: created, want: false},
+		{name: "no detail-type", pattern: `{"source":["orders.service"]}`, filter: created, want: true},
+		{
+			name:    "source matches but detail-type does not",
+			pattern: `{"source":["orders.service"],"detail-type":["ORDER_CANCELLED"]}`,
+			filter:  Filter{Sources: []string{"orders.service"}, DetailTypes: []string{"ORDER_CREATED"}},
+			want:    false,
+		},
+		{name: "prefix matcher", pattern: `{"detail-type":[{"prefix":"ITEM_"}]}`, filter: created, want: true},
+		{name: "anything-but matcher", pattern: `{"source":[{"anything-but":"orders.service"}]}`, filter: orders, want: true},
+		{name: "plain value beside a matcher", pattern: `{"source":["inventory.service",{"prefix":"orders."}]}`, filter: orders, want: true},
+		{name: "not a list", pattern: `{"source":"inventory.service"}`, filter: orders, want: true},
+		{name: "invalid pattern", pattern: `{"source":`, filter: orders, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := patternMatches(tt.pattern, tt.filter)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("patternMatches returned error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("patternMatches(%s) = %v, want %v", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
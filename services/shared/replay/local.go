@@ -0,0 +1,111 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// maxLineSize bounds one event in a JSONL file. EventBridge events are at
+// most 256 KB.
+const maxLineSize = 1024 * 1024
+
+// Summary counts the events of a local replay.
+type Summary struct {
+	Read     int
+	Replayed int
+	Skipped  int
+	Failed   int
+}
+
+// Local replays events from r, one EventBridge event per line as delivered
+// to rule targets, into handle. Events outside the window or not matching
+// the filter are skipped. A handler error is logged and counted, and the
+// replay continues unless stopOnError is set.
+func Local(ctx context.Context, r io.Reader, window Window, filter Filter, stopOnError bool, handle func(ctx context.Context, event events.CloudWatchEvent) error) (Summary, error) {
+	var summary Summary
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event events.CloudWatchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return summary, fmt.Errorf("line %d: invalid event: %v", line, err)
+		}
+		summary.Read++
+		if !window.Contains(event.Time) || !filter.Matches(event.Source, event.DetailType) {
+			summary.Skipped++
+			continue
+		}
+
+		if err := handle(ctx, event); err != nil {
+			summary.Failed++
+			log.Printf("line %d: %s %s failed: %v", line, event.DetailType, event.ID, err)
+			if stopOnError {
+				return summary, fmt.Errorf("line %d: %v", line, err)
+			}
+			continue
+		}
+		summary.Replayed++
+	}
+	if err := scanner.Err(); err != nil {
+		return summary, fmt.Errorf("failed to read events: %v", err)
+	}
+	return summary, nil
+}
+
+// RunLocal is the local replay command line shared by the replay tools:
+// it parses args, replays the file into handle and prints the summary.
+func RunLocal(ctx context.Context, args []string, handle func(ctx context.Context, event events.CloudWatchEvent) error) error {
+	flags := flag.NewFlagSet("local", flag.ContinueOnError)
+	file := flags.String("file", "", "JSONL file of EventBridge events")
+	from := flags.String("from", "", "replay events at or after this time")
+	to := flags.String("to", "", "replay events before this time")
+	stopOnError := flags.Bool("stop-on-error", false, "stop at the first handler error")
+	var filter Filter
+	flags.Var((*Strings)(&filter.Sources), "source", "replay events from this source (repeatable)")
+	flags.Var((*Strings)(&filter.DetailTypes), "detail-type", "replay events of this detail type (repeatable)")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	var window Window
+	var err error
+	if window.Start, err = ParseTime(*from); err != nil {
+		return err
+	}
+	if window.End, err = ParseTime(*to); err != nil {
+		return err
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", *file, err)
+	}
+	defer f.Close()
+
+	summary, err := Local(ctx, f, window, filter, *stopOnError, handle)
+	fmt.Printf("read %d, replayed %d, skipped %d, failed %d\n", summary.Read, summary.Replayed, summary.Skipped, summary.Failed)
+	if err != nil {
+		return err
+	}
+	if summary.Failed > 0 {
+		return fmt.Errorf("%d events failed", summary.Failed)
+	}
+	return nil
+}
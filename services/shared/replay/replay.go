@@ -0,0 +1,72 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Filter selects events by source and detail type. An empty list matches
+// everything.
+type Filter struct {
+	Sources     []string
+	DetailTypes []string
+}
+
+func (f Filter) Matches(source, detailType string) bool {
+	return matchesAny(f.Sources, source) && matchesAny(f.DetailTypes, detailType)
+}
+
+func (f Filter) empty() bool {
+	return len(f.Sources) == 0 && len(f.DetailTypes) == 0
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Window is the time range [Start, End) of the events to replay. A zero
+// bound is open.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (w Window) Contains(t time.Time) bool {
+	return (w.Start.IsZero() || !t.Before(w.Start)) && (w.End.IsZero() || t.Before(w.End))
+}
+
+// ParseTime accepts RFC 3339 timestamps and dates.
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// Strings is a flag.Value collecting a repeated string flag.
+type Strings []string
+
+func (s *Strings) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *Strings) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,89 @@
+package replay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFilterMatches(t *testing.T) {
+	tests := []struct {
+		name       string
+		filter     Filter
+		source     string
+		detailType string
+		want       bool
+	}{
+		{"empty filter", Filter{}, "orders.service", "ORDER_CREATED", true},
+		{"source listed", Filter{Sources: []string{"inventory.service", "orders.service"}}, "orders.service", "ORDER_CREATED", true},
+		{"source not listed", Filter{Sources: []string{"inventory.service"}}, "orders.service", "ORDER_CREATED", false},
+		{"detail type listed", Filter{DetailTypes: []string{"ORDER_CREATED"}}, "orders.service", "ORDER_CREATED", true},
+		{"detail type not listed", Filter{DetailTypes: []string{"ORDER_CANCELLED"}}, "orders.service", "ORDER_CREATED", false},
+		{"both listed", Filter{Sources: []string{"orders.service"}, DetailTypes: []string{"ORDER_CREATED"}}, "orders.service", "ORDER_CREATED", true},
+		{"only source listed", Filter{Sources: []string{"orders.service"}, DetailTypes: []string{"ORDER_CANCELLED"}}, "orders.service", "ORDER_CREATED", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.source, tt.detailType); got != tt.want {
+				t.Errorf("Matches(%q, %q) = %v, want %v", tt.source, tt.detailType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWindowContains(t *testing.T) {
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		window Window
+		t      time.Time
+		want   bool
+	}{
+		{"open window", Window{}, start, true},
+		{"at the start", Window{Start: start, End: end}, start, true},
+		{"before the start", Window{Start: start, End: end}, start.Add(-time.Nanosecond), false},
+		{"inside", Window{Start: start, End: end}, start.Add(12 * time.Hour), true},
+		{"just before the end", Window{Start: start, End: end}, end.Add(-time.Nanosecond), true},
+		{"at the end", Window{Start: start, End: end}, end, false},
+		{"open start", Window{End: end}, start.AddDate(-1, 0, 0), true},
+		{"open end", Window{Start: start}, end.AddDate(1, 0, 0), true},
+		{"same instant in another zone", Window{Start: start, End: end}, end.In(time.FixedZone("UTC+2", 2*60*60)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.Contains(tt.t); got != tt.want {
+				t.Errorf("Contains(%v) = %v, want %v", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: ""},
+		{value: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{value: "2024-05-01T10:30:00Z", want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{value: "2024-05-01T12:30:00+02:00", want: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)},
+		{value: "2024-05-01 10:30", wantErr: true},
+		{value: "01/05/2024", wantErr: true},
+		{value: "2024-13-01", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseTime(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseTime(%q) = %v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+module serp/services/shared/saga
+
+go 1.21
+
+require serp/services/shared/events v0.0.0-00010101000000-000000000000
+
+replace serp/services/shared/events => ../events
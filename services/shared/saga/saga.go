@@ -0,0 +1,167 @@
+package saga
+
+import (
+	"context"
+	"fmt"
+
+	"serp/services/shared/events"
+)
+
+// Task is an action of a service invoked by the saga. Each service with
+// saga tasks deploys one function that dispatches on Action.
+type Task struct {
+	Service string
+	Action  string
+}
+
+func (t Task) String() string {
+	return t.Service + "." + t.Action
+}
+
+// Step is one forward step of a saga. A step without a Task is a
+// placeholder that passes its input through. Compensation undoes the step
+// once it has succeeded and a later step fails.
+type Step struct {
+	Name         string
+	Task         *Task
+	Compensation *Task
+}
+
+// Saga is a sequence of steps. When a step fails, the compensations of
+// the steps that completed run most recent first, followed by Abort.
+//
+// Failures are the error names of task failures that decide the outcome,
+// such as an order without stock; they fail the step at once. Other task
+// errors are retried before they fail it.
+type Saga struct {
+	Name     string
+	Steps    []Step
+	Abort    Task
+	Failures []string
+}
+
+// Input is the saga execution input, and the payload of every task
+// invocation with Action set. EventID and CorrelationID are those of the
+// ORDER_CREATED event that started the execution; tasks use EventID to
+// apply each action once per order.
+type Input struct {
+	Action        string             `json:"action,omitempty"`
+	EventID       string             `json:"eventId"`
+	CorrelationID string             `json:"correlationId"`
+	OrderID       string             `json:"orderId"`
+	Items         []events.OrderLine `json:"items"`
+}
+
+const (
+	ServiceInventory = "inventory"
+	ServiceOrders    = "orders"
+)
+
+// Task actions handled by the services' saga functions.
+const (
+	ActionReserve = "reserve"
+	ActionRestore = "restore"
+	ActionConfirm = "confirm"
+	ActionAbort   = "abort"
+)
+
+// Fulfilment takes a created order through stock reservation and
+// confirmation. It ends with the order CONFIRMED: payment is not
+// implemented yet, and shipping happens outside the saga, which moves the
+// order on through updateOrderStatus. A failed order is rejected if it was
+// never confirmed and cancelled otherwise.
+var Fulfilment = Saga{
+	Name: "OrderFulfilment",
+	Steps: []Step{
+		{
+			Name:         "ReserveStock",
+			Task:         &Task{ServiceInventory, ActionReserve},
+			Compensation: &Task{ServiceInventory, ActionRestore},
+		},
+		{
+			Name: "ConfirmOrder",
+			Task: &Task{ServiceOrders, ActionConfirm},
+		},
+		{
+			// Payment is not implemented yet.
+			Name: "ProcessPayment",
+		},
+	},
+	Abort: Task{ServiceOrders, ActionAbort},
+	Failures: []string{
+		"NotReservedError",
+		"InvalidTransitionError",
+		"OrderNotFoundError",
+	},
+}
+
+// Services returns the services the saga invokes, in order of first use.
+func (s Saga) Services() []string {
+	var services []string
+	seen := make(map[string]bool)
+	add := func(task *Task) {
+		if task != nil && !seen[task.Service] {
+			seen[task.Service] = true
+			services = append(services, task.Service)
+		}
+	}
+	for _, step := range s.Steps {
+		add(step.Task)
+		add(step.Compensation)
+	}
+	add(&s.Abort)
+	return services
+}
+
+// Invoker runs a task, locally or against a deployed service.
+type Invoker interface {
+	Invoke(ctx context.Context, task Task, input Input) error
+}
+
+// Result records an execution: the tasks invoked in order and the error
+// that failed it, if any.
+type Result struct {
+	Invoked []Task
+	Failed  string
+	Err     error
+}
+
+// Run executes s with the same semantics as the state machine deployed
+// for it, without retrying failed tasks, which makes it usable against
+// local fakes. Compensation and
+// abort failures stop the execution, as a failed state does.
+func Run(ctx context.Context, s Saga, invoker Invoker, input Input) Result {
+	var result Result
+	invoke := func(task Task) error {
+		result.Invoked = append(result.Invoked, task)
+		taskInput := input
+		taskInput.Action = task.Action
+		return invoker.Invoke(ctx, task, taskInput)
+	}
+
+	for i, step := range s.Steps {
+		if step.Task == nil {
+			continue
+		}
+		err := invoke(*step.Task)
+		if err == nil {
+			continue
+		}
+
+		result.Failed = step.Name
+		result.Err = err
+		for j := i - 1; j >= 0; j-- {
+			if compensation := s.Steps[j].Compensation; compensation != nil {
+				if err := invoke(*compensation); err != nil {
+					result.Err = fmt.Errorf("compensation %s failed after %s failed: %v", compensation, step.Name, err)
+					return result
+				}
+			}
+		}
+		if err := invoke(s.Abort); err != nil {
+			result.Err = fmt.Errorf("abort %s failed after %s failed: %v", s.Abort, step.Name, err)
+		}
+		return result
+	}
+	return result
+}
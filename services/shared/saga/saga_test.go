@@ -0,0 +1,87 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// scripted fails the tasks in failures and records every invocation.
+type scripted struct {
+	failures map[Task]error
+	inputs   []Input
+}
+
+func (s *scripted) Invoke(ctx context.Context, task Task, input Input) error {
+	s.inputs = append(s.inputs, input)
+	return s.failures[task]
+}
+
+var (
+	reserve = Task{ServiceInventory, ActionReserve}
+	restore = Task{ServiceInventory, ActionRestore}
+	confirm = Task{ServiceOrders, ActionConfirm}
+	abort   = Task{ServiceOrders, ActionAbort}
+)
+
+func TestRun(t *testing.T) {
+	unavailable := errors.New("unavailable")
+	tests := []struct {
+		name        string
+		failures    map[Task]error
+		wantInvoked []Task
+		wantFailed  string
+	}{
+		{
+			name:        "runs every step",
+			wantInvoked: []Task{reserve, confirm},
+		},
+		{
+			name:        "aborts without compensating the failed step",
+			failures:    map[Task]error{reserve: unavailable},
+			wantInvoked: []Task{reserve, abort},
+			wantFailed:  "ReserveStock",
+		},
+		{
+			name:        "compensates the completed steps before aborting",
+			failures:    map[Task]error{confirm: unavailable},
+			wantInvoked: []Task{reserve, confirm, restore, abort},
+			wantFailed:  "ConfirmOrder",
+		},
+		{
+			name:        "stops when a compensation fails",
+			failures:    map[Task]error{confirm: unavailable, restore: unavailable},
+			wantInvoked: []Task{reserve, confirm, restore},
+			wantFailed:  "ConfirmOrder",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoker := &scripted{failures: tt.failures}
+			result := Run(context.Background(), Fulfilment, invoker, Input{OrderID: "order-1"})
+
+			if !reflect.DeepEqual(result.Invoked, tt.wantInvoked) {
+				t.Errorf("invoked %v, want %v", result.Invoked, tt.wantInvoked)
+			}
+			if result.Failed != tt.wantFailed {
+				t.Errorf("failed step %q, want %q", result.Failed, tt.wantFailed)
+			}
+			if (result.Err != nil) != (tt.wantFailed != "") {
+				t.Errorf("error %v for failed step %q", result.Err, tt.wantFailed)
+			}
+			for i, input := range invoker.inputs {
+				if input.Action != result.Invoked[i].Action || input.OrderID != "order-1" {
+					t.Errorf("task %s got input %+v", result.Invoked[i], input)
+				}
+			}
+		})
+	}
+}
+
+func TestServices(t *testing.T) {
+	want := []string{ServiceInventory, ServiceOrders}
+	if got := Fulfilment.Services(); !reflect.DeepEqual(got, want) {
+		t.Errorf("services %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,20 @@
+module serp/services/shared/streams
+
+go 1.21
+
+require (
+	github.com/aws/aws-lambda-go v1.46.0
+	github.com/aws/aws-sdk-go-v2 v1.25.3
+	github.com/aws/aws-sdk-go-v2/service/dynamodb v1.30.4
+	github.com/aws/aws-sdk-go-v2/service/eventbridge v1.26.0
+	serp/services/shared/events v0.0.0-00010101000000-000000000000
+)
+
+replace serp/services/shared/events => ../events
+
+require (
+	github.com/aws/aws-sdk-go-v2/internal/configsources v1.3.3 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/endpoints/v2 v2.6.3 // indirect
+	github.com/aws/aws-sdk-go-v2/internal/v4a v1.2.6 // indirect
+	github.com/aws/smithy-go v1.20.1 // indirect
+)
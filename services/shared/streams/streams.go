@@ -0,0 +1,180 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	erpevents "serp/services/shared/events"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+const (
+	Insert = "INSERT"
+	Modify = "MODIFY"
+	Remove = "REMOVE"
+)
+
+// maxPutEventsEntries is the PutEvents limit per request.
+const maxPutEventsEntries = 10
+
+// Change is a DynamoDB stream record with its images converted to SDK
+// attribute values, so they can be read with the services' unmarshallers.
+type Change struct {
+	EventID  string
+	Name     string
+	Keys     map[string]types.AttributeValue
+	OldImage map[string]types.AttributeValue
+	NewImage map[string]types.AttributeValue
+}
+
+// Key returns a string key attribute such as PK or SK.
+func (c Change) Key(name string) string {
+	if v, ok := c.Keys[name].(*types.AttributeValueMemberS); ok {
+		return v.Value
+	}
+	return ""
+}
+
+func Decode(record events.DynamoDBEventRecord) (Change, error) {
+	keys, err := convertImage(record.Change.Keys)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to decode keys of record %s: %v", record.EventID, err)
+	}
+	oldImage, err := convertImage(record.Change.OldImage)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to decode old image of record %s: %v", record.EventID, err)
+	}
+	newImage, err := convertImage(record.Change.NewImage)
+	if err != nil {
+		return Change{}, fmt.Errorf("failed to decode new image of record %s: %v", record.EventID, err)
+	}
+	return Change{
+		EventID:  record.EventID,
+		Name:     record.EventName,
+		Keys:     keys,
+		OldImage: oldImage,
+		NewImage: newImage,
+	}, nil
+}
+
+// Process hands each record of a stream batch to handle in order. On the
+// first failure it stops and reports that record as the batch item
+// failure, so Lambda checkpoints the records before it and retries from
+// there, bisecting the batch until the failing record is isolated.
+func Process(ctx context.Context, event events.DynamoDBEvent, handle func(ctx context.Context, change Change) error) events.DynamoDBEventResponse {
+	for _, record := range event.Records {
+		change, err := Decode(record)
+		if err == nil {
+			err = handle(ctx, change)
+		}
+		if err != nil {
+			log.Printf("stream record %s failed: %v", record.EventID, err)
+			return events.DynamoDBEventResponse{
+				BatchItemFailures: []events.DynamoDBBatchItemFailure{
+					{ItemIdentifier: record.Change.SequenceNumber},
+				},
+			}
+		}
+	}
+	return events.DynamoDBEventResponse{}
+}
+
+func convertImage(image map[string]events.DynamoDBAttributeValue) (map[string]types.AttributeValue, error) {
+	if image == nil {
+		return nil, nil
+	}
+	result := make(map[string]types.AttributeValue, len(image))
+	for name, value := range image {
+		av, err := convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %s: %v", name, err)
+		}
+		result[name] = av
+	}
+	return result, nil
+}
+
+func convert(value events.DynamoDBAttributeValue) (types.AttributeValue, error) {
+	switch value.DataType() {
+	case events.DataTypeString:
+		return &types.AttributeValueMemberS{Value: value.String()}, nil
+	case events.DataTypeNumber:
+		return &types.AttributeValueMemberN{Value: value.Number()}, nil
+	case events.DataTypeBinary:
+		return &types.AttributeValueMemberB{Value: value.Binary()}, nil
+	case events.DataTypeBoolean:
+		return &types.AttributeValueMemberBOOL{Value: value.Boolean()}, nil
+	case events.DataTypeNull:
+		return &types.AttributeValueMemberNULL{Value: true}, nil
+	case events.DataTypeStringSet:
+		return &types.AttributeValueMemberSS{Value: value.StringSet()}, nil
+	case events.DataTypeNumberSet:
+		return &types.AttributeValueMemberNS{Value: value.NumberSet()}, nil
+	case events.DataTypeBinarySet:
+		return &types.AttributeValueMemberBS{Value: value.BinarySet()}, nil
+	case events.DataTypeList:
+		list := value.List()
+		result := make([]types.AttributeValue, len(list))
+		for i, element := range list {
+			av, err := convert(element)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = av
+		}
+		return &types.AttributeValueMemberL{Value: result}, nil
+	case events.DataTypeMap:
+		result, err := convertImage(value.Map())
+		if err != nil {
+			return nil, err
+		}
+		return &types.AttributeValueMemberM{Value: result}, nil
+	default:
+		return nil, fmt.Errorf("unsupported data type %d", value.DataType())
+	}
+}
+
+// Entry encodes event as a PutEvents entry for eventBusName, with the
+// event's source and type as the entry's Source and DetailType.
+func Entry(eventBusName string, event *erpevents.Event) (eventbridgetypes.PutEventsRequestEntry, error) {
+	detail, err := erpevents.Encode(event)
+	if err != nil {
+		return eventbridgetypes.PutEventsRequestEntry{}, err
+	}
+	return eventbridgetypes.PutEventsRequestEntry{
+		Source:       aws.String(event.Source),
+		DetailType:   aws.String(event.Type),
+		Detail:       aws.String(string(detail)),
+		EventBusName: aws.String(eventBusName),
+	}, nil
+}
+
+// Publisher puts events on a bus. *eventbridge.Client is the Publisher of
+// the deployed functions.
+type Publisher interface {
+	PutEvents(ctx context.Context, params *eventbridge.PutEventsInput, optFns ...func(*eventbridge.Options)) (*eventbridge.PutEventsOutput, error)
+}
+
+// Publish sends entries to EventBridge in batches of ten, failing if any
+// entry is rejected so the stream record is retried.
+func Publish(ctx context.Context, client Publisher, entries []eventbridgetypes.PutEventsRequestEntry) error {
+	for start := 0; start < len(entries); start += maxPutEventsEntries {
+		end := min(start+maxPutEventsEntries, len(entries))
+		result, err := client.PutEvents(ctx, &eventbridge.PutEventsInput{
+			Entries: entries[start:end],
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send events: %v", err)
+		}
+		if result.FailedEntryCount > 0 {
+			return fmt.Errorf("failed to send %d of %d events", result.FailedEntryCount, end-start)
+		}
+	}
+	return nil
+}